@@ -9,4 +9,10 @@ type Boilerplate struct {
 	Value string
 	// Count is the number of times this boilerplate has been used.
 	Count int
+	// Raw disables expansion: the value is used as-is, placeholders included.
+	Raw bool
+	// Trusted allows the value to run shell commands when expanded, as in {{$(git branch --show-current)}}.
+	Trusted bool
+	// Scaffold makes the value describe a tree of files instead of a single text.
+	Scaffold bool
 }
@@ -55,22 +55,60 @@ func NewSQLiteDatabase(dbPath string) (*SQLiteDatabase, error) {
 	return sqliteDB, nil
 }
 
-// initSchema creates the boilerplates table if it doesn't exist
+// initSchema creates the boilerplates table if it doesn't exist,
+// and adds the columns missing from tables created by older versions
 func (s *SQLiteDatabase) initSchema() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS boilerplates (
 		name TEXT PRIMARY KEY,
 		value TEXT NOT NULL,
-		count INTEGER DEFAULT 0
+		count INTEGER DEFAULT 0,
+		raw INTEGER NOT NULL DEFAULT 0,
+		trusted INTEGER NOT NULL DEFAULT 0,
+		scaffold INTEGER NOT NULL DEFAULT 0
 	);`
 
-	_, err := s.db.Exec(query)
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	if err := s.addColumnIfMissing("raw", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("trusted", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return s.addColumnIfMissing("scaffold", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// addColumnIfMissing adds a column to the boilerplates table if it doesn't exist
+func (s *SQLiteDatabase) addColumnIfMissing(name, definition string) error {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info('boilerplates')")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return err
+		}
+		if column == name {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE boilerplates ADD COLUMN %s %s", name, definition))
 	return err
 }
 
 // GetAllBoilerplates returns all boilerplates as a map with name as key
 func (s *SQLiteDatabase) GetAllBoilerplates() (map[string]*boilerplate.Boilerplate, error) {
-	query := "SELECT name, value, count FROM boilerplates ORDER BY name"
+	query := "SELECT name, value, count, raw, trusted, scaffold FROM boilerplates ORDER BY name"
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -80,7 +118,7 @@ func (s *SQLiteDatabase) GetAllBoilerplates() (map[string]*boilerplate.Boilerpla
 	boilerplates := make(map[string]*boilerplate.Boilerplate)
 	for rows.Next() {
 		b := &boilerplate.Boilerplate{}
-		if err := rows.Scan(&b.Name, &b.Value, &b.Count); err != nil {
+		if err := rows.Scan(&b.Name, &b.Value, &b.Count, &b.Raw, &b.Trusted, &b.Scaffold); err != nil {
 			return nil, err
 		}
 		boilerplates[b.Name] = b
@@ -91,11 +129,11 @@ func (s *SQLiteDatabase) GetAllBoilerplates() (map[string]*boilerplate.Boilerpla
 
 // GetBoilerplateByName returns a specific boilerplate by name
 func (s *SQLiteDatabase) GetBoilerplateByName(name string) (*boilerplate.Boilerplate, error) {
-	query := "SELECT name, value, count FROM boilerplates WHERE name = ?"
+	query := "SELECT name, value, count, raw, trusted, scaffold FROM boilerplates WHERE name = ?"
 	row := s.db.QueryRow(query, name)
 
 	var b boilerplate.Boilerplate
-	err := row.Scan(&b.Name, &b.Value, &b.Count)
+	err := row.Scan(&b.Name, &b.Value, &b.Count, &b.Raw, &b.Trusted, &b.Scaffold)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("unknown boilerplate %q", name)
@@ -108,15 +146,15 @@ func (s *SQLiteDatabase) GetBoilerplateByName(name string) (*boilerplate.Boilerp
 
 // CreateBoilerplate creates a new boilerplate
 func (s *SQLiteDatabase) CreateBoilerplate(bp *boilerplate.Boilerplate) error {
-	query := "INSERT INTO boilerplates (name, value, count) VALUES (?, ?, ?)"
-	_, err := s.db.Exec(query, bp.Name, bp.Value, bp.Count)
+	query := "INSERT INTO boilerplates (name, value, count, raw, trusted, scaffold) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := s.db.Exec(query, bp.Name, bp.Value, bp.Count, bp.Raw, bp.Trusted, bp.Scaffold)
 	return err
 }
 
 // UpdateBoilerplate updates an existing boilerplate
 func (s *SQLiteDatabase) UpdateBoilerplate(bp *boilerplate.Boilerplate) error {
-	query := "UPDATE boilerplates SET value = ?, count = ? WHERE name = ?"
-	result, err := s.db.Exec(query, bp.Value, bp.Count, bp.Name)
+	query := "UPDATE boilerplates SET value = ?, count = ?, raw = ?, trusted = ?, scaffold = ? WHERE name = ?"
+	result, err := s.db.Exec(query, bp.Value, bp.Count, bp.Raw, bp.Trusted, bp.Scaffold, bp.Name)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"path/filepath"
 	"testing"
 
@@ -92,9 +93,21 @@ func TestSQLiteDatabase_Integration(t *testing.T) {
 			Count: 2,
 		},
 		{
-			Name:  "reminder",
-			Value: "Don't forget about {{event}} on {{date}}",
-			Count: 5,
+			Name:    "reminder",
+			Value:   "Don't forget about {{event}} on {{date}}",
+			Count:   5,
+			Trusted: true,
+		},
+		{
+			Name:  "go-template",
+			Value: "{{ .Name }}",
+			Count: 1,
+			Raw:   true,
+		},
+		{
+			Name:     "go-module",
+			Value:    "==> {{module}}/go.mod <==\nmodule {{module}}\n",
+			Scaffold: true,
 		},
 	}
 
@@ -113,7 +126,7 @@ func TestSQLiteDatabase_Integration(t *testing.T) {
 		require.NoError(t, err, "Failed to get all boilerplates")
 
 		// Check we have the right number
-		assert.Len(t, allBoilerplates, 3, "Should have 3 boilerplates")
+		assert.Len(t, allBoilerplates, 5, "Should have 5 boilerplates")
 
 		// Verify each boilerplate
 		for _, expected := range testBoilerplates {
@@ -123,6 +136,9 @@ func TestSQLiteDatabase_Integration(t *testing.T) {
 			assert.Equal(t, expected.Name, actual.Name, "Name should match")
 			assert.Equal(t, expected.Value, actual.Value, "Value should match")
 			assert.Equal(t, expected.Count, actual.Count, "Count should match")
+			assert.Equal(t, expected.Raw, actual.Raw, "Raw should match")
+			assert.Equal(t, expected.Trusted, actual.Trusted, "Trusted should match")
+			assert.Equal(t, expected.Scaffold, actual.Scaffold, "Scaffold should match")
 		}
 
 		// Test individual retrieval
@@ -215,10 +231,10 @@ func TestSQLiteDatabase_Integration(t *testing.T) {
 		require.Error(t, err, "Should error when getting deleted boilerplate")
 		assert.Nil(t, deletedBP, "Deleted boilerplate should return nil")
 
-		// Verify we now have 2 boilerplates instead of 3
+		// Verify we now have 3 boilerplates instead of 4
 		allBoilerplates, err := db.GetAllBoilerplates()
 		require.NoError(t, err, "Failed to get all boilerplates after deletion")
-		assert.Len(t, allBoilerplates, 2, "Should have 2 boilerplates after deletion")
+		assert.Len(t, allBoilerplates, 4, "Should have 4 boilerplates after deletion")
 	})
 
 	// Close the database (this will be called by defer as well, but testing explicitly)
@@ -399,3 +415,36 @@ func TestSQLiteDatabase_Persistence(t *testing.T) {
 		}
 	})
 }
+
+func TestSQLiteDatabase_MigrateSchema(t *testing.T) {
+	// Create a temporary database file with the original schema
+	tempDir := t.TempDir()
+	dbPath := filepath.Join(tempDir, "ezbp.db")
+
+	oldDB, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = oldDB.Exec(`CREATE TABLE boilerplates (name TEXT PRIMARY KEY, value TEXT NOT NULL, count INTEGER DEFAULT 0);
+		INSERT INTO boilerplates (name, value, count) VALUES ('greeting', 'Hello {{name}}', 3);`)
+	require.NoError(t, err)
+	require.NoError(t, oldDB.Close())
+
+	// Opening it adds the missing columns, keeping existing rows
+	db, err := NewSQLiteDatabase(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bp, err := db.GetBoilerplateByName("greeting")
+	require.NoError(t, err)
+	assert.Equal(t, &boilerplate.Boilerplate{Name: "greeting", Value: "Hello {{name}}", Count: 3}, bp)
+
+	bp.Raw = true
+	bp.Trusted = true
+	bp.Scaffold = true
+	require.NoError(t, db.UpdateBoilerplate(bp))
+
+	bp, err = db.GetBoilerplateByName("greeting")
+	require.NoError(t, err)
+	assert.True(t, bp.Raw, "Raw should be updated")
+	assert.True(t, bp.Trusted, "Trusted should be updated")
+	assert.True(t, bp.Scaffold, "Scaffold should be updated")
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 )
 
 // DefaultEditor returns the user's preferred editor.
@@ -74,7 +75,8 @@ func createTempFile(initialContent string) (string, error) {
 	return f.Name(), nil
 }
 
-// openEditor opens the specified file in the user's default editor
+// openEditor opens the specified file in the user's default editor.
+// The editor command can include arguments, as in "code --wait".
 func openEditor(editor, filename string) error {
 	editor = DefaultEditor(editor)
 
@@ -82,7 +84,11 @@ func openEditor(editor, filename string) error {
 	if runtime.GOOS == "windows" {
 		cmd = exec.Command("cmd", "/c", editor, filename)
 	} else {
-		cmd = exec.Command(editor, filename)
+		args := strings.Fields(editor)
+		if len(args) == 0 {
+			return fmt.Errorf("invalid editor %q", editor)
+		}
+		cmd = exec.Command(args[0], append(args[1:], filename)...)
 	}
 
 	// Connect editor to terminal
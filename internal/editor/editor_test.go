@@ -51,6 +51,15 @@ func TestDefaultEditor(t *testing.T) {
 	})
 }
 
+func TestEdit_BlankEditor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Editors are run through cmd on Windows.")
+	}
+
+	_, err := Edit("  ", "content")
+	assert.EqualError(t, err, `invalid editor "  "`)
+}
+
 func TestEdit(t *testing.T) {
 	if os.Getenv("MANUAL_TEST") != "1" {
 		t.Skip("Skipping manual test; set MANUAL_TEST=1 to run.")
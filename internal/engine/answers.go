@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Answers pre-answer the variables of an expansion, by variable name.
+// The items of multi-select variables are separated by newlines.
+type Answers map[string]string
+
+// Add adds value to the answer of the variable called name.
+// Adding several values to a variable makes a list, as for multi-select variables.
+func (a Answers) Add(name, value string) {
+	if previous, found := a[name]; found {
+		value = previous + listSeparator + value
+	}
+	a[name] = value
+}
+
+// LoadAnswers reads answers from a JSON or TOML file, depending on its extension.
+// Values may be strings, numbers, booleans or lists of them.
+func LoadAnswers(path string) (Answers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answers file: %w", err)
+	}
+
+	var values map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported answers file %s: expected a .json or .toml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode answers file %s: %w", path, err)
+	}
+
+	answers, err := answersFrom(values)
+	if err != nil {
+		return nil, fmt.Errorf("answers file %s: %w", path, err)
+	}
+	return answers, nil
+}
+
+// LoadDataset reads rows of answers from a CSV or JSON file, depending on its extension.
+// A CSV file starts with a header row giving the variable of each column. Repeating
+// a column makes a list, as for multi-select variables. A JSON file is an array of
+// objects, whose values are those of an answers file, see LoadAnswers.
+func LoadDataset(path string) ([]Answers, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	var rows []Answers
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		rows, err = csvRows(data)
+	case ".json":
+		rows, err = jsonRows(data)
+	default:
+		return nil, fmt.Errorf("unsupported dataset %s: expected a .csv or .json file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode dataset %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("dataset %s has no rows", path)
+	}
+	return rows, nil
+}
+
+func csvRows(data []byte) ([]Answers, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	header := records[0]
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		if header[i] == "" {
+			return nil, fmt.Errorf("column %d: empty variable name", i+1)
+		}
+	}
+
+	rows := make([]Answers, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := Answers{}
+		for i, value := range record {
+			row.Add(header[i], value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func jsonRows(data []byte) ([]Answers, error) {
+	var objects []map[string]any
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, err
+	}
+
+	rows := make([]Answers, 0, len(objects))
+	for i, values := range objects {
+		row, err := answersFrom(values)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// answersFrom converts decoded values to answers.
+func answersFrom(values map[string]any) (Answers, error) {
+	answers := make(Answers, len(values))
+	for name, value := range values {
+		switch value := value.(type) {
+		case []any:
+			for _, item := range value {
+				if err := addAnswer(answers, name, item); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if err := addAnswer(answers, name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return answers, nil
+}
+
+func addAnswer(answers Answers, name string, value any) error {
+	switch value := value.(type) {
+	case string:
+		answers.Add(name, value)
+	case bool, int64, float64:
+		answers.Add(name, fmt.Sprint(value))
+	default:
+		return fmt.Errorf("unexpected value for %q: %T", name, value)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnswers_Add(t *testing.T) {
+	answers := Answers{}
+	answers.Add("name", "Alice")
+	answers.Add("services", "api")
+	answers.Add("services", "db")
+
+	assert.Equal(t, Answers{"name": "Alice", "services": "api\ndb"}, answers)
+}
+
+func TestLoadAnswers(t *testing.T) {
+	dir := t.TempDir()
+	want := Answers{"title": "DB down", "count": "3", "rollback": "true", "services": "api\ndb"}
+
+	for file, content := range map[string]string{
+		"answers.json": `{"title": "DB down", "count": 3, "rollback": true, "services": ["api", "db"]}`,
+		"answers.toml": "title = \"DB down\"\ncount = 3\nrollback = true\nservices = [\"api\", \"db\"]\n",
+	} {
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		answers, err := LoadAnswers(path)
+		require.NoError(t, err, file)
+		assert.Equal(t, want, answers, file)
+	}
+
+	path := filepath.Join(dir, "answers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("title: DB down"), 0o600))
+	_, err := LoadAnswers(path)
+	assert.ErrorContains(t, err, "expected a .json or .toml file")
+
+	path = filepath.Join(dir, "nested.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"title": {"text": "DB down"}}`), 0o600))
+	_, err = LoadAnswers(path)
+	assert.ErrorContains(t, err, `unexpected value for "title"`)
+}
+
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	want := []Answers{
+		{"name": "Alice", "email": "alice@example.com", "services": "api\ndb"},
+		{"name": "Bob", "email": "bob@example.com", "services": "web\n"},
+	}
+
+	for file, content := range map[string]string{
+		"rows.csv": "name,email,services,services\nAlice,alice@example.com,api,db\nBob,bob@example.com,web,\n",
+		"rows.json": `[{"name": "Alice", "email": "alice@example.com", "services": ["api", "db"]},
+			{"name": "Bob", "email": "bob@example.com", "services": ["web", ""]}]`,
+	} {
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		rows, err := LoadDataset(path)
+		require.NoError(t, err, file)
+		assert.Equal(t, want, rows, file)
+	}
+
+	for file, wantErr := range map[string]string{
+		"rows.txt":    "expected a .csv or .json file",
+		"empty.csv":   "has no rows",
+		"header.csv":  "column 2: empty variable name",
+		"ragged.csv":  "wrong number of fields",
+		"nested.json": `row 1: unexpected value for "name"`,
+	} {
+		content := map[string]string{
+			"empty.csv":   "name,email\n",
+			"header.csv":  "name, \nAlice,x\n",
+			"ragged.csv":  "name,email\nAlice\n",
+			"nested.json": `[{"name": {"first": "Alice"}}]`,
+		}[file]
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		_, err := LoadDataset(path)
+		assert.ErrorContains(t, err, wantErr, file)
+	}
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// Replaced in tests.
+var (
+	timeNow          = time.Now
+	clipboardReadAll = clipboard.ReadAll
+)
+
+// builtinFunc computes the value of a built-in variable.
+// arg holds whatever follows the variable name in the placeholder, trimmed.
+type builtinFunc func(ex *expansion, arg string) (string, error)
+
+// builtins are the variables resolved by the engine without prompting, as in "{{@date}}".
+var builtins = map[string]builtinFunc{
+	"date":      dateBuiltin("2006-01-02"),
+	"time":      dateBuiltin("15:04"),
+	"datetime":  dateBuiltin("2006-01-02 15:04"),
+	"user":      noArgBuiltin(currentUser),
+	"hostname":  noArgBuiltin(os.Hostname),
+	"cwd":       noArgBuiltin(os.Getwd),
+	"clipboard": noArgBuiltin(func() (string, error) { return clipboardReadAll() }),
+}
+
+// dateBuiltin returns a built-in formatting the expansion time.
+// Its optional arguments are an offset (e.g. "+7d", "-2w", "+3h") and a Go time layout,
+// the given layout being used when there is none.
+func dateBuiltin(defaultLayout string) builtinFunc {
+	return func(ex *expansion, arg string) (string, error) {
+		t, layout := ex.now, defaultLayout
+		if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+			offset, rest, _ := strings.Cut(arg, " ")
+			var err error
+			if t, err = applyOffset(t, offset); err != nil {
+				return "", err
+			}
+			arg = strings.TrimSpace(rest)
+		}
+
+		if arg != "" {
+			layout = arg
+			if unquoted, err := strconv.Unquote(arg); err == nil {
+				layout = unquoted
+			}
+		}
+		return t.Format(layout), nil
+	}
+}
+
+// applyOffset shifts t by an offset such as "+7d".
+// Days (d), weeks (w), months (mo) and years (y) follow the calendar,
+// other units are those of time.ParseDuration (h, m, s...).
+func applyOffset(t time.Time, offset string) (time.Time, error) {
+	for _, unit := range []struct {
+		suffix              string
+		years, months, days int
+	}{
+		{"d", 0, 0, 1},
+		{"w", 0, 0, 7},
+		{"mo", 0, 1, 0},
+		{"y", 1, 0, 0},
+	} {
+		if value, found := strings.CutSuffix(offset, unit.suffix); found {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return t, fmt.Errorf("invalid date offset %q", offset)
+			}
+			return t.AddDate(n*unit.years, n*unit.months, n*unit.days), nil
+		}
+	}
+
+	d, err := time.ParseDuration(offset)
+	if err != nil {
+		return t, fmt.Errorf("invalid date offset %q", offset)
+	}
+	return t.Add(d), nil
+}
+
+// noArgBuiltin returns a built-in taking no argument whose value is computed by fn.
+func noArgBuiltin(fn func() (string, error)) builtinFunc {
+	return func(ex *expansion, arg string) (string, error) {
+		if arg != "" {
+			return "", fmt.Errorf("unexpected argument %q", arg)
+		}
+		return fn()
+	}
+}
+
+// currentUser returns the name of the current user, from $USER or from the system.
+func currentUser() (string, error) {
+	if name := os.Getenv("USER"); name != "" {
+		return name, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", errors.New("unable to determine the current user")
+	}
+	return u.Username, nil
+}
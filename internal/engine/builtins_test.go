@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOffset(t *testing.T) {
+	base := time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC)
+
+	for offset, want := range map[string]time.Time{
+		"+7d":  time.Date(2024, time.February, 7, 10, 0, 0, 0, time.UTC),
+		"-2w":  time.Date(2024, time.January, 17, 10, 0, 0, 0, time.UTC),
+		"+1mo": time.Date(2024, time.March, 2, 10, 0, 0, 0, time.UTC),
+		"+1y":  time.Date(2025, time.January, 31, 10, 0, 0, 0, time.UTC),
+		"+3h":  time.Date(2024, time.January, 31, 13, 0, 0, 0, time.UTC),
+		"-30m": time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC),
+	} {
+		got, err := applyOffset(base, offset)
+		require.NoError(t, err, offset)
+		assert.Equal(t, want, got, offset)
+	}
+
+	for _, offset := range []string{"+xd", "+7", "+7q"} {
+		_, err := applyOffset(base, offset)
+		assert.EqualError(t, err, `invalid date offset "`+offset+`"`)
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// runCommand runs a command and returns its output, without trailing newlines.
+// Trusted commands are run by the shell. Others must be allowed by the configuration
+// and are run directly, so that they can't be chained with other commands.
+// It fails if the command takes longer than the configured timeout,
+// the error including what the command wrote on its standard error.
+func (ex *expansion) runCommand(command string, trusted bool) (string, error) {
+	args := []string{"sh", "-c", command}
+	if !trusted {
+		if !commandAllowed(ex.bm.config.AllowedCommands, command) {
+			return "", fmt.Errorf("%w: %q (add it to allowed_commands or trust the boilerplate)", ErrCommandNotAllowed, command)
+		}
+		var err error
+		if args, err = splitArgs(command); err != nil {
+			return "", fmt.Errorf("command %q: %w", command, err)
+		}
+	}
+	if strings.TrimSpace(command) == "" {
+		return "", errors.New("missing command")
+	}
+
+	timeout := ex.bm.config.CommandTimeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait for the processes started by the command once it is killed.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("command %q timed out after %s", command, timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("command %q: %w: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("command %q: %w", command, err)
+	}
+
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// commandAllowed reports whether a command is equal to an entry of the allowlist,
+// or starts with one followed by a space.
+func commandAllowed(allowlist []string, command string) bool {
+	command = strings.TrimSpace(command)
+	for _, allowed := range allowlist {
+		allowed = strings.TrimSpace(allowed)
+		if allowed != "" && (command == allowed || strings.HasPrefix(command, allowed+" ")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// condition is the test of an {{#if}} block. It is one of:
+//   - name: true if the variable is not empty.
+//   - !name: true if the variable is empty.
+//   - a == b, a != b: compares two operands.
+//
+// Operands are variable names or double-quoted strings.
+type condition struct {
+	negate bool
+	left   operand
+	op     string // "==", "!=" or empty for a non-empty test
+	right  operand
+}
+
+// operand is a variable or a literal string used in a condition.
+type operand struct {
+	variable string
+	literal  string
+}
+
+// parseCondition parses the argument of an {{#if}} or {{else if}} placeholder.
+func parseCondition(s string) (*condition, error) {
+	tokens, err := tokenizeCondition(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("missing condition")
+	}
+
+	cond := &condition{}
+	if tokens[0] == "!" {
+		cond.negate = true
+		tokens = tokens[1:]
+	}
+
+	switch {
+	case len(tokens) == 1:
+		if cond.left, err = parseOperand(tokens[0]); err != nil {
+			return nil, err
+		}
+	case len(tokens) == 3 && (tokens[1] == "==" || tokens[1] == "!="):
+		if cond.left, err = parseOperand(tokens[0]); err != nil {
+			return nil, err
+		}
+		if cond.right, err = parseOperand(tokens[2]); err != nil {
+			return nil, err
+		}
+		cond.op = tokens[1]
+	default:
+		return nil, fmt.Errorf("invalid condition %q", s)
+	}
+
+	return cond, nil
+}
+
+// tokenizeCondition splits a condition into operators, names and quoted strings.
+func tokenizeCondition(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!="):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case c == '!':
+			tokens = append(tokens, "!")
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string in condition %q", s)
+			}
+			tokens = append(tokens, quoted)
+			i += len(quoted)
+		default:
+			end := strings.IndexAny(s[i:], " \t!=\"")
+			if end < 0 {
+				end = len(s) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid condition %q", s)
+			}
+			tokens = append(tokens, s[i:i+end])
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+func parseOperand(token string) (operand, error) {
+	if strings.HasPrefix(token, `"`) {
+		literal, err := strconv.Unquote(token)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid string %s", token)
+		}
+		return operand{literal: literal}, nil
+	}
+	if !variableNameRe.MatchString(token) {
+		return operand{}, fmt.Errorf("invalid variable name %q", token)
+	}
+	return operand{variable: token}, nil
+}
+
+// evalCondition evaluates a condition, resolving the variables it uses.
+func (ex *expansion) evalCondition(cond *condition) (bool, error) {
+	left, err := ex.operand(cond.left)
+	if err != nil {
+		return false, err
+	}
+
+	var result bool
+	switch cond.op {
+	case "":
+		result = strings.TrimSpace(left) != ""
+	default:
+		right, err := ex.operand(cond.right)
+		if err != nil {
+			return false, err
+		}
+		result = (left == right) == (cond.op == "==")
+	}
+
+	return result != cond.negate, nil
+}
+
+func (ex *expansion) operand(o operand) (string, error) {
+	if o.variable == "" {
+		return o.literal, nil
+	}
+	return ex.variable(o.variable)
+}
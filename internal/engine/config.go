@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/driquet/ezbp/internal/editor"
@@ -14,11 +15,30 @@ import (
 type Config struct {
 	// DatabasePath specifies the path to the SQLite database file.
 	DatabasePath string `toml:"database_path"`
-	// DefaultUI specifies the default user interface to use ("terminal" or "rofi").
+	// DefaultUI specifies the default user interface to use ("terminal", "rofi" or "none").
+	// The "none" UI never interacts with the user and answers every question with its default value.
+	// The command line expands with ExpandOptions.NonInteractive when it is selected.
 	// This can be overridden by the --ui command-line flag.
 	DefaultUI string `toml:"default_ui"`
 	// Editor specifies the text editor command to use for editing boilerplates.
 	Editor string `toml:"editor"`
+	// MaxIncludeDepth is the maximum nesting level of included boilerplates.
+	MaxIncludeDepth int `toml:"max_include_depth"`
+	// MultiSelectSeparator joins the answers of a multi-select placeholder, ", " by default.
+	// It can be overridden for a placeholder with the join or bullets filters.
+	MultiSelectSeparator string `toml:"multi_select_separator"`
+	// AllowedCommands lists the shell commands boilerplates may run, as in {{$(git branch --show-current)}}.
+	// An entry allows the commands equal to it or starting with it followed by a space,
+	// e.g. "git rev-parse" allows "git rev-parse HEAD". Trusted boilerplates may run any command.
+	AllowedCommands []string `toml:"allowed_commands"`
+	// CommandTimeout is the maximum duration of a shell command, 5s by default.
+	CommandTimeout time.Duration `toml:"command_timeout"`
+	// Output is where expanded boilerplates are written: "clipboard" (the default), "stdout",
+	// "file:<path>" or "append:<path>". See ParseOutput. It can be overridden by the --output flag.
+	Output string `toml:"output"`
+	// ClipboardClearAfter, if positive, is the delay after which the clipboard is cleared
+	// when it holds a boilerplate expanded with secret answers, as in {{Token:secret}}.
+	ClipboardClearAfter time.Duration `toml:"clipboard_clear_after"`
 	// Rofi holds configuration specific to the Rofi user interface.
 	// These settings are only active if DefaultUI is "rofi" or if Rofi is selected via the --ui flag.
 	Rofi ui.RofiConfig `toml:"rofi"`
@@ -27,6 +47,8 @@ type Config struct {
 const (
 	defaultConfigFileName   = "config.toml"
 	defaultDatabaseFileName = "ezbp.db"
+	defaultMaxIncludeDepth  = 10
+	defaultCommandTimeout   = 5 * time.Second
 )
 
 // ConfigDirPath returns the path to the application's configuration directory
@@ -66,9 +88,13 @@ func LoadConfigFromFile(configDir string) (Config, error) {
 	}
 
 	defaultConfig := Config{
-		DatabasePath: filepath.Join(configDir, defaultDatabaseFileName),
-		DefaultUI:    "terminal", // Default UI is terminal
-		Rofi:         defaultRofiConfig,
+		DatabasePath:         filepath.Join(configDir, defaultDatabaseFileName),
+		DefaultUI:            "terminal", // Default UI is terminal
+		MaxIncludeDepth:      defaultMaxIncludeDepth,
+		MultiSelectSeparator: defaultListSeparator,
+		CommandTimeout:       defaultCommandTimeout,
+		Output:               defaultOutput,
+		Rofi:                 defaultRofiConfig,
 	}
 
 	if _, err := os.Stat(configFilePath); os.IsNotExist(err) {
@@ -89,13 +115,36 @@ func LoadConfigFromFile(configDir string) (Config, error) {
 		defaultTomlContent := fmt.Sprintf(`database_path = "%s"
 
 # default_ui specifies the default user interface.
-# Valid options are "terminal" or "rofi".
+# Valid options are "terminal", "rofi" or "none" (non-interactive, default values only).
 # This can be overridden by the --ui command-line flag.
 default_ui = "%s"
 
 # editor specifies the text editor command to use for editing boilerplates.
 editor = "%s"
 
+# max_include_depth is the maximum nesting level of [[included]] boilerplates.
+max_include_depth = %d
+
+# multi_select_separator joins the answers of {{multi-select[]|a|b|c}} placeholders.
+multi_select_separator = %q
+
+# allowed_commands lists the shell commands that boilerplates may run with {{$(command)}}.
+# An entry allows the commands starting with it, e.g. "git rev-parse" allows "git rev-parse HEAD".
+# Boilerplates added or edited with --trusted may run any command.
+# allowed_commands = ["git rev-parse", "git branch --show-current"]
+
+# command_timeout is the maximum duration of a shell command.
+command_timeout = "%s"
+
+# output is where expanded boilerplates are written: "clipboard", "stdout",
+# "file:<path>" (overwritten) or "append:<path>".
+# This can be overridden by the --output command-line flag.
+output = "%s"
+
+# clipboard_clear_after clears the clipboard after this delay when it holds
+# a boilerplate expanded with secret answers ({{Token:secret}}). Disabled if unset.
+# clipboard_clear_after = "30s"
+
 # Rofi User Interface settings
 # These settings are used if default_ui = "rofi" or --ui=rofi is specified.
 [rofi]
@@ -110,9 +159,16 @@ editor = "%s"
   # Extra arguments to pass to Rofi for input dialogs (e.g., free-form text prompts).
   # Example: input_args = ["-password"] (for password-style input)
   # input_args = []
+  # Optional: graphical editor used for multi-line prompts ({{Description:text}}).
+  # If empty, multi-line answers are typed on a single line, \n standing for a new line.
+  # editor = "gedit"
 `, defaultConfig.DatabasePath, // Use Go's string formatting to escape path if needed
 			defaultConfig.DefaultUI,
 			editor.DefaultEditor(""),
+			defaultConfig.MaxIncludeDepth,
+			defaultConfig.MultiSelectSeparator,
+			defaultConfig.CommandTimeout,
+			defaultConfig.Output,
 			defaultConfig.Rofi.Path,
 		)
 
@@ -136,10 +192,29 @@ editor = "%s"
 	}
 
 	// Validate DefaultUI or set to default
-	if loadedConfig.DefaultUI != "rofi" && loadedConfig.DefaultUI != "terminal" {
+	if loadedConfig.DefaultUI != "rofi" && loadedConfig.DefaultUI != "terminal" && loadedConfig.DefaultUI != "none" {
 		loadedConfig.DefaultUI = defaultConfig.DefaultUI
 	}
 
+	if loadedConfig.MaxIncludeDepth <= 0 {
+		loadedConfig.MaxIncludeDepth = defaultConfig.MaxIncludeDepth
+	}
+
+	if loadedConfig.MultiSelectSeparator == "" {
+		loadedConfig.MultiSelectSeparator = defaultConfig.MultiSelectSeparator
+	}
+
+	if loadedConfig.CommandTimeout <= 0 {
+		loadedConfig.CommandTimeout = defaultConfig.CommandTimeout
+	}
+
+	if loadedConfig.Output == "" {
+		loadedConfig.Output = defaultConfig.Output
+	}
+	if _, err := ParseOutput(loadedConfig.Output); err != nil {
+		return Config{}, fmt.Errorf("invalid config file %s: %w", configFilePath, err)
+	}
+
 	// Ensure Rofi.Path defaults to "rofi" if it's empty after decoding,
 	// which could happen if the [Rofi] table exists but 'path' is missing or empty.
 	if loadedConfig.Rofi.Path == "" {
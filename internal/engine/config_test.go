@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,7 +24,17 @@ func TestLoadConfig_NoConfigFileExists(t *testing.T) {
 	// Verify default values
 	assert.Equal(t, "terminal", config.DefaultUI, "DefaultUI should be 'terminal'")
 	assert.Equal(t, "rofi", config.Rofi.Path, "RofiUI.Path should be 'rofi'")
+	assert.Equal(t, defaultMaxIncludeDepth, config.MaxIncludeDepth, "MaxIncludeDepth should be %d", defaultMaxIncludeDepth)
+	assert.Equal(t, ", ", config.MultiSelectSeparator, "MultiSelectSeparator should be ', '")
 	assert.Equal(t, expectedDatabasePath, config.DatabasePath, "DatabasePathe should be '%s'", expectedConfigFilePath)
+
+	// The created file should give back the same configuration
+	reloaded, err := LoadConfigFromFile(configDir)
+	require.NoError(t, err)
+	assert.Equal(t, config.MultiSelectSeparator, reloaded.MultiSelectSeparator)
+	assert.Equal(t, config.MaxIncludeDepth, reloaded.MaxIncludeDepth)
+	assert.Equal(t, defaultCommandTimeout, reloaded.CommandTimeout)
+	assert.Equal(t, "clipboard", reloaded.Output)
 }
 
 func TestLoadConfig_ConfigFileExistsValid(t *testing.T) {
@@ -38,6 +49,11 @@ func TestLoadConfig_ConfigFileExistsValid(t *testing.T) {
 	fileContent := []byte(fmt.Sprintf(`
 database_path = "%s"
 default_ui = "rofi"
+max_include_depth = 3
+multi_select_separator = " / "
+allowed_commands = ["git rev-parse"]
+command_timeout = "1m"
+output = "append:/tmp/ezbp.log"
 [rofi]
   path = "%s"
 `, customDatabasePath, customRofiPath))
@@ -49,6 +65,22 @@ default_ui = "rofi"
 	assert.Equal(t, customDatabasePath, config.DatabasePath)
 	assert.Equal(t, "rofi", config.DefaultUI)
 	assert.Equal(t, customRofiPath, config.Rofi.Path)
+	assert.Equal(t, 3, config.MaxIncludeDepth)
+	assert.Equal(t, " / ", config.MultiSelectSeparator)
+	assert.Equal(t, []string{"git rev-parse"}, config.AllowedCommands)
+	assert.Equal(t, time.Minute, config.CommandTimeout)
+	assert.Equal(t, "append:/tmp/ezbp.log", config.Output)
+}
+
+func TestLoadConfig_ConfigFileExistsInvalidOutput(t *testing.T) {
+	configDir := t.TempDir()
+
+	configFilePath := filepath.Join(configDir, defaultConfigFileName)
+	err := os.WriteFile(configFilePath, []byte(`output = "printer"`), 0600)
+	require.NoError(t, err)
+
+	_, err = LoadConfigFromFile(configDir)
+	assert.ErrorContains(t, err, `invalid output "printer"`)
 }
 
 func TestLoadConfig_ConfigFileExistsInvalidDefaultUI(t *testing.T) {
@@ -7,7 +7,6 @@ import (
 	"io"
 	"maps"
 	"os"
-	"regexp"
 	"slices"
 	"strings"
 
@@ -27,23 +26,24 @@ type Engine struct {
 var (
 	ErrBoilerplateAlreadyExist = errors.New("boilerplate already exists")
 	ErrBoilerplateUnknown      = errors.New("boilerplate not found")
+	ErrIncludeCycle            = errors.New("include cycle")
+	ErrIncludeTooDeep          = errors.New("too many nested includes")
+	ErrCommandNotAllowed       = errors.New("command not allowed")
+	ErrMissingAnswers          = errors.New("missing answers")
 )
 
-// variableRe is a regular expression used to find variables in boilerplate strings.
-// It matches variables in two formats:
-// - [[variable_name]]: Represents another boilerplate to be included.
-// - {{prompt}}: Represents a user prompt.
-var variableRe = regexp.MustCompile(`(\[\[([a-zA-Z0-9_]+)\]\]|{{[^}]+}})`)
-
 // NewEngine creates a new Engine.
 // It loads the configuration, initializes the database, loads boilerplates,
 // and sets up the UI based on preference (CLI flag > config > default).
 func NewEngine(db database.Database, config Config) (*Engine, error) {
 	var selectedUI ui.UI
-	if config.DefaultUI == "rofi" {
+	switch config.DefaultUI {
+	case "rofi":
 		selectedUI = ui.NewRofiUI(config.Rofi)
-	} else { // finalUiChoice == "terminal" or any other fallback
-		selectedUI = ui.NewTerminalUI()
+	case "none":
+		selectedUI = ui.NewNonInteractiveUI()
+	default: // "terminal" or any other fallback
+		selectedUI = ui.NewTerminalUI(ui.TerminalConfig{Editor: config.Editor})
 	}
 
 	boilerplates, err := db.GetAllBoilerplates()
@@ -85,29 +85,26 @@ func (bm *Engine) SelectBoilerplate() (string, error) {
 // Add creates a new boilerplate with the given name and value.
 // Returns an error if the name or value is empty, or if a boilerplate with the same name already exists.
 func (bm *Engine) Add(name string, value string) error {
-	if name == "" {
-		return errors.New("empty boilerplate name")
-	}
+	return bm.AddBoilerplate(&boilerplate.Boilerplate{Name: name, Value: value})
+}
 
-	if value == "" {
-		return errors.New("empty boilerplate value")
+// AddBoilerplate creates a new boilerplate, along with its Raw, Trusted and Scaffold flags.
+// Returns an error if its name or value is empty, if it is a scaffold whose value doesn't
+// describe a tree of files, or if a boilerplate with the same name already exists.
+func (bm *Engine) AddBoilerplate(bp *boilerplate.Boilerplate) error {
+	if err := checkBoilerplate(bp); err != nil {
+		return err
 	}
 
-	if _, found := bm.boilerplates[name]; found {
+	if _, found := bm.boilerplates[bp.Name]; found {
 		return ErrBoilerplateAlreadyExist
 	}
 
-	bp := &boilerplate.Boilerplate{
-		Name:  name,
-		Value: value,
-		Count: 0,
-	}
-
-	// Add boilerplate both to local map and database.
-	bm.boilerplates[name] = bp
+	// Add boilerplate both to database and local map.
 	if err := bm.db.CreateBoilerplate(bp); err != nil {
 		return err
 	}
+	bm.boilerplates[bp.Name] = bp
 
 	return nil
 }
@@ -115,26 +112,55 @@ func (bm *Engine) Add(name string, value string) error {
 // Edit updates the value of an existing boilerplate.
 // Returns an error if the name or value is empty, or if the boilerplate doesn't exist.
 func (bm *Engine) Edit(name string, value string) error {
-	if name == "" {
-		return errors.New("empty boilerplate name")
+	edited := boilerplate.Boilerplate{Name: name, Value: value}
+	if bp, found := bm.boilerplates[name]; found {
+		edited = *bp
+		edited.Value = value
 	}
+	return bm.EditBoilerplate(&edited)
+}
 
-	if value == "" {
-		return errors.New("empty boilerplate value")
+// EditBoilerplate replaces an existing boilerplate, along with its Raw, Trusted and Scaffold
+// flags. Its usage count is kept. Returns an error if its name or value is empty, if it is a
+// scaffold whose value doesn't describe a tree of files, or if the boilerplate doesn't exist.
+func (bm *Engine) EditBoilerplate(bp *boilerplate.Boilerplate) error {
+	if err := checkBoilerplate(bp); err != nil {
+		return err
 	}
 
-	bp, found := bm.boilerplates[name]
+	current, found := bm.boilerplates[bp.Name]
 	if !found {
 		return ErrBoilerplateUnknown
 	}
 
-	bp.Value = value
+	edited := *bp
+	edited.Count = current.Count
 
-	// Edit boilerplate both in local map and database.
-	bm.boilerplates[name] = bp
-	if err := bm.db.UpdateBoilerplate(bp); err != nil {
+	// Edit boilerplate both in database and local map.
+	if err := bm.db.UpdateBoilerplate(&edited); err != nil {
 		return err
 	}
+	*current = edited
+
+	return nil
+}
+
+// checkBoilerplate returns an error if the name or value of a boilerplate is empty,
+// or if it is a scaffold whose value doesn't describe a tree of files.
+func checkBoilerplate(bp *boilerplate.Boilerplate) error {
+	if bp.Name == "" {
+		return errors.New("empty boilerplate name")
+	}
+
+	if bp.Value == "" {
+		return errors.New("empty boilerplate value")
+	}
+
+	if bp.Scaffold {
+		if _, err := splitScaffold(bp.Value); err != nil {
+			return fmt.Errorf("invalid scaffold %q: %w", bp.Name, err)
+		}
+	}
 
 	return nil
 }
@@ -157,35 +183,160 @@ func (bm *Engine) Delete(name string) error {
 	return nil
 }
 
-// Expand recursively expands a boilerplate template by its name.
-// It replaces all variables in the boilerplate string with their corresponding values.
-// Variables can be either other boilerplates or user prompts.
+// HasSecrets reports whether expanding a boilerplate may ask secret prompts,
+// such as {{Token:secret}}, including in the boilerplates it includes.
+func (bm *Engine) HasSecrets(name string) (bool, error) {
+	ex := bm.newExpansion(name)
+	nodes, err := ex.parseBoilerplate(name)
+	if err != nil {
+		return false, err
+	}
+	return ex.hasSecrets(nodes), nil
+}
+
+// TemplateHasSecrets reports whether expanding a template, see ExpandTemplate, may ask secret prompts.
+func (bm *Engine) TemplateHasSecrets(template string) (bool, error) {
+	nodes, err := parseTemplate(template)
+	if err != nil {
+		return false, err
+	}
+	return bm.newExpansion(templateName).hasSecrets(nodes), nil
+}
+
+// Expand expands a boilerplate template by its name.
+// The template is parsed once and evaluated in a single pass: placeholders are
+// replaced by the user's answers and included boilerplates are expanded recursively.
+// Each variable is asked once, its answer being reused by every placeholder
+// referencing it, including in included boilerplates. The variables are asked
+// up front in a single form, except those of conditional branches.
+// Answers are inserted verbatim and never interpreted as template syntax.
 // The usage count of the boilerplate is incremented after expansion, both in memory and in the database.
 func (bm *Engine) Expand(name string) (string, error) {
-	bp, found := bm.boilerplates[name]
-	if !found {
-		return "", fmt.Errorf("unknown boilerplate %q", name)
+	return bm.ExpandWith(name, ExpandOptions{})
+}
+
+// ExpandOptions holds the optional settings of an expansion.
+type ExpandOptions struct {
+	// Answers pre-answer variables, which are then not asked.
+	// Answers of typed prompts must be valid.
+	Answers Answers
+	// NonInteractive never asks anything: variables without answer get their default value,
+	// and the expansion fails with ErrMissingAnswers, listing the variables without default.
+	NonInteractive bool
+	// DryRun doesn't increment the usage count of the boilerplate, see RecordUsage.
+	DryRun bool
+}
+
+// ExpandWith expands a boilerplate template by its name, as Expand does, with options.
+func (bm *Engine) ExpandWith(name string, opts ExpandOptions) (string, error) {
+	value, _, err := bm.expandWith(name, opts)
+	return value, err
+}
+
+// expandWith expands a boilerplate as ExpandWith does, also returning the answers of its secret prompts.
+func (bm *Engine) expandWith(name string, opts ExpandOptions) (string, []string, error) {
+	if _, found := bm.boilerplates[name]; !found {
+		return "", nil, fmt.Errorf("unknown boilerplate %q", name)
+	}
+
+	ex := bm.newExpansion(name)
+	nodes, err := ex.parseBoilerplate(name)
+	if err != nil {
+		return "", nil, err
+	}
+	value, err := ex.expand(nodes, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !opts.DryRun {
+		bm.RecordUsage(name)
+	}
+
+	return value, ex.secrets(), nil
+}
+
+// ExpandTemplate expands a template which is not a saved boilerplate, as ExpandWith does.
+// Its includes are resolved against the saved boilerplates, and no usage count is incremented.
+// Like untrusted boilerplates, it may only run the allowed commands.
+func (bm *Engine) ExpandTemplate(template string, opts ExpandOptions) (string, error) {
+	value, _, err := bm.expandTemplate(template, opts)
+	return value, err
+}
+
+// expandTemplate expands a template as ExpandTemplate does, also returning the answers of its secret prompts.
+func (bm *Engine) expandTemplate(template string, opts ExpandOptions) (string, []string, error) {
+	nodes, err := parseTemplate(template)
+	if err != nil {
+		return "", nil, err
+	}
+	ex := bm.newExpansion(templateName)
+	value, err := ex.expand(nodes, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return value, ex.secrets(), nil
+}
+
+// ExpandReviewed expands a boilerplate as ExpandWith does, then lets the user review the result
+// before it is used: it can be edited, or the boilerplate expanded again with new answers.
+// accept describes what accepting the result does, such as "copy to clipboard".
+// The answers of secret prompts are masked in the reviewed result, as UIs such as Rofi
+// receive it on their command line.
+// It returns false if the user discarded the result. The usage count of the boilerplate
+// is only incremented once the result is accepted.
+func (bm *Engine) ExpandReviewed(name string, opts ExpandOptions, accept string) (string, bool, error) {
+	opts.DryRun = true
+	value, ok, err := bm.review(func() (string, []string, error) { return bm.expandWith(name, opts) }, accept)
+	if ok {
+		bm.RecordUsage(name)
+	}
+	return value, ok, err
+}
+
+// ExpandTemplateReviewed expands a template as ExpandTemplate does, then lets the user
+// review the result as ExpandReviewed does.
+func (bm *Engine) ExpandTemplateReviewed(template string, opts ExpandOptions, accept string) (string, bool, error) {
+	return bm.review(func() (string, []string, error) { return bm.expandTemplate(template, opts) }, accept)
+}
+
+// review lets the user review the result of expand, which is called again to re-answer.
+// expand also returns the answers of secret prompts, masked in the reviewed result.
+// It returns false if the user discarded the result.
+func (bm *Engine) review(expand func() (string, []string, error), accept string) (string, bool, error) {
+	value, secrets, err := expand()
+	if err != nil {
+		return "", false, err
 	}
 
-	before := bp.Value
-	var after string
-	var err error
 	for {
-		after, err = bm.expandFirst(before)
+		action, err := bm.ui.Review(maskSecrets(value, secrets), accept)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
-		if before == after {
-			break
+
+		switch action {
+		case ui.ReviewAccept:
+			return value, true, nil
+		case ui.ReviewEdit:
+			value, err = bm.ui.Prompt("Edit the result", ui.PromptOptions{Default: value, Multiline: true})
+		case ui.ReviewReanswer:
+			value, secrets, err = expand()
+		default:
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
 		}
-		before = after
 	}
+}
 
+// RecordUsage increments the usage count of a boilerplate, both in memory and in the database.
+// Failures are only reported as warnings, as they don't prevent using the boilerplate.
+func (bm *Engine) RecordUsage(name string) {
 	if err := bm.incrementBoilerplateCount(name); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to increment count for boilerplate %s in database: %v\n", name, err)
 	}
-
-	return after, nil
 }
 
 func (bm *Engine) incrementBoilerplateCount(name string) error {
@@ -201,64 +352,6 @@ func (bm *Engine) incrementBoilerplateCount(name string) error {
 	return nil
 }
 
-// expandFirst finds and expands the first variable in a boilerplate string.
-// Variables are identified by the variableRe regular expression.
-// If the variable is a boilerplate inclusion (e.g., "[[another_boilerplate]]"),
-// it replaces the variable with the value of the referenced boilerplate.
-// If the variable is a user prompt (e.g., "{{Enter your name:}}"),
-// it prompts the user for input and replaces the variable with the user's response.
-// It can also handle prompts with a fixed set of answers (e.g., "{{Select color|red|green|blue}}").
-func (bm *Engine) expandFirst(value string) (string, error) {
-	// Find the first variable part to expand using the precompiled regular expression.
-	loc := variableRe.FindStringIndex(value)
-	if loc == nil {
-		// No variable part found, return the original string.
-		return value, nil
-	}
-
-	// Extract the variable part and its inner content.
-	start, end := loc[0], loc[1]
-	outerValue := value[start:end]       // e.g., "[[some_boilerplate]]" or "{{some_prompt}}"
-	innerValue := value[start+2 : end-2] // e.g., "some_boilerplate" or "some_prompt"
-	var replacement string
-
-	// Check if the variable is a boilerplate inclusion or a user prompt
-	// based on the starting character ('[' for boilerplate, '{' for prompt).
-	if value[start] == '[' {
-		// Substitution by another boilerplate.
-		bp, found := bm.boilerplates[outerValue]
-		if !found {
-			return "", fmt.Errorf("unknown referenced boilerplate %q", innerValue)
-		}
-		replacement = bp.Value
-	} else {
-		// User prompt.
-		// It can consist in asking the user an open question {{prompt}}
-		// Or in asking a question with a fixed set of answers {{prompt|a|b|c}}.
-		if idx := strings.IndexRune(innerValue, '|'); idx >= 0 {
-			// Prompt with a fixed set of answers.
-			elements := strings.Split(innerValue, "|")
-			prompt := elements[0]
-			options := elements[1:]
-			choice, err := bm.ui.Select(prompt, options)
-			if err != nil {
-				return "", err
-			}
-			replacement = choice
-		} else {
-			// Open question prompt.
-			input, err := bm.ui.Prompt(innerValue)
-			if err != nil {
-				return "", err
-			}
-			replacement = input
-		}
-	}
-
-	// Replace the variable part with the determined replacement.
-	return value[:start] + replacement + value[end:], nil
-}
-
 // ImportBoilerplatesFromCSV loads boilerplates from a CSV file at the given path.
 // It expects a header row with "name,value" and adds or updates entries accordingly.
 func (bm *Engine) ImportBoilerplatesFromCSV(path string) error {
@@ -314,7 +407,7 @@ func (bm *Engine) ImportBoilerplatesFromCSV(path string) error {
 					"Update value",
 					"Keep current value (for all)",
 					"Update value (for all)",
-				})
+				}, "")
 			if err != nil {
 				return fmt.Errorf("user prompt failed: %w", err)
 			}
@@ -1 +1,724 @@
 package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driquet/ezbp/internal/boilerplate"
+	"github.com/driquet/ezbp/internal/database"
+	"github.com/driquet/ezbp/internal/ui"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUI answers prompts and selections from a predefined map, keyed by prompt label.
+// Questions without a predefined answer get their default value.
+// Forms are answered field by field, and their prompts recorded in forms.
+// Their previews are recorded before and after answering them, with pending
+// variables between angle brackets. Reviewed results are recorded, and get the
+// next action of reviews, or are accepted.
+type fakeUI struct {
+	answers  map[string]string
+	asked    []string
+	forms    [][]string
+	previews []string
+	reviews  []ui.ReviewAction
+	reviewed []string
+}
+
+func (u *fakeUI) SelectBoilerplate(boilerplates map[string]*boilerplate.Boilerplate) (string, error) {
+	return "", ErrBoilerplateUnknown
+}
+
+func (u *fakeUI) Select(prompt string, choices []string, defaultValue string) (string, error) {
+	return u.Prompt(prompt, ui.PromptOptions{Default: defaultValue})
+}
+
+// MultiSelect answers with the predefined answer split on newlines, if any.
+func (u *fakeUI) MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error) {
+	u.asked = append(u.asked, prompt)
+	if answer, found := u.answers[prompt]; found {
+		return strings.Split(answer, "\n"), nil
+	}
+	return defaultValues, nil
+}
+
+func (u *fakeUI) Prompt(prompt string, opts ui.PromptOptions) (string, error) {
+	u.asked = append(u.asked, prompt)
+	if answer, found := u.answers[prompt]; found {
+		return answer, nil
+	}
+	return opts.Default, nil
+}
+
+func (u *fakeUI) Form(fields []ui.Field, preview ui.Preview) error {
+	return u.form(u, fields, preview)
+}
+
+// form records the prompts of a form, and answers it with the methods of self,
+// so that types embedding fakeUI can override them.
+func (u *fakeUI) form(self ui.UI, fields []ui.Field, preview ui.Preview) error {
+	var prompts []string
+	for _, field := range fields {
+		prompts = append(prompts, field.Prompt)
+	}
+	u.forms = append(u.forms, prompts)
+
+	pending := func(label string) string { return "<" + label + ">" }
+	if preview != nil {
+		u.previews = append(u.previews, preview(pending))
+	}
+	if err := ui.AskFields(self, fields); err != nil {
+		return err
+	}
+	if preview != nil {
+		u.previews = append(u.previews, preview(pending))
+	}
+	return nil
+}
+
+func (u *fakeUI) Review(result string, accept string) (ui.ReviewAction, error) {
+	u.reviewed = append(u.reviewed, result)
+	if len(u.reviews) == 0 {
+		return ui.ReviewAccept, nil
+	}
+	action := u.reviews[0]
+	u.reviews = u.reviews[1:]
+	return action, nil
+}
+
+// newTestEngine creates an engine backed by a temporary database holding the given boilerplates.
+func newTestEngine(t *testing.T, u ui.UI, boilerplates map[string]string) *Engine {
+	t.Helper()
+
+	db, err := database.NewSQLiteDatabase(filepath.Join(t.TempDir(), "ezbp.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	bm, err := NewEngine(db, Config{})
+	require.NoError(t, err)
+	bm.ui = u
+
+	for name, value := range boilerplates {
+		require.NoError(t, bm.Add(name, value))
+	}
+
+	return bm
+}
+
+// editBoilerplate changes a copy of the boilerplate called name, and saves it with EditBoilerplate.
+func editBoilerplate(t *testing.T, bm *Engine, name string, change func(bp *boilerplate.Boilerplate)) {
+	t.Helper()
+
+	bp, found := bm.Get(name)
+	require.True(t, found, name)
+	edited := *bp
+	change(&edited)
+	require.NoError(t, bm.EditBoilerplate(&edited))
+}
+
+func TestExpand(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{
+		"Your name": "Alice",
+		"Color":     "green",
+	}}
+	bm := newTestEngine(t, u, map[string]string{
+		"greeting":  "Hello {{Your name}}, you like {{Color|red|green}}.\n[[signature]]",
+		"signature": "-- The team",
+	})
+
+	value, err := bm.Expand("greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Alice, you like green.\n-- The team", value)
+
+	bp, _ := bm.Get("greeting")
+	assert.Equal(t, 1, bp.Count)
+}
+
+func TestExpand_AnswersAreNotParsed(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{
+		"Code": "{{Injected}} [[signature]]",
+	}}
+	bm := newTestEngine(t, u, map[string]string{
+		"snippet":   "Code: {{Code}}",
+		"signature": "-- The team",
+	})
+
+	value, err := bm.Expand("snippet")
+	require.NoError(t, err)
+	assert.Equal(t, "Code: {{Injected}} [[signature]]", value)
+	assert.Equal(t, []string{"Code"}, u.asked)
+}
+
+func TestExpand_NamedVariables(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{
+		"Your name":   "Alice",
+		"Environment": "prod",
+	}}
+	bm := newTestEngine(t, u, map[string]string{
+		"report":    "Hi, {{name}} here. Deploying to {{env: Environment|dev|prod}}.\n{{env}} is ready.\n[[signature]]",
+		"signature": "-- {{name: Your name}}, {{Your name}}",
+	})
+
+	value, err := bm.Expand("report")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi, Alice here. Deploying to prod.\nprod is ready.\n-- Alice, Alice", value)
+	assert.Equal(t, []string{"Your name", "Environment", "Your name"}, u.asked)
+}
+
+func TestExpand_Defaults(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{
+		"Title": "Outage",
+	}}
+	bm := newTestEngine(t, u, map[string]string{
+		"ticket": "{{Title=Bug}} [{{Ticket priority=P2}}] in {{Env|dev|*prod|staging}}",
+	})
+
+	value, err := bm.Expand("ticket")
+	require.NoError(t, err)
+	assert.Equal(t, "Outage [P2] in prod", value)
+}
+
+func TestExpand_Conditionals(t *testing.T) {
+	const incident = `Incident: {{Title}}, rolled back: {{rolled_back: Rolled back?|yes|no}}
+{{#if rolled_back == "yes"}}
+Rollback: {{Rollback details}}
+{{else}}
+No rollback.
+{{/if}}
+{{#if !impact}}
+No customer impact.
+{{/if}}
+[[footer]]`
+
+	for _, tc := range []struct {
+		answers map[string]string
+		want    string
+	}{
+		{
+			answers: map[string]string{"Title": "DB down", "Rolled back?": "yes", "Rollback details": "v1.2", "Impact": "none"},
+			want:    "Incident: DB down, rolled back: yes\nRollback: v1.2\nImpact: none",
+		},
+		{
+			answers: map[string]string{"Title": "DB down", "Rolled back?": "no", "Impact": ""},
+			want:    "Incident: DB down, rolled back: no\nNo rollback.\nNo customer impact.\nImpact: ",
+		},
+	} {
+		u := &fakeUI{answers: tc.answers}
+		bm := newTestEngine(t, u, map[string]string{
+			"incident": incident,
+			// impact is used in a condition before being defined here.
+			"footer": "Impact: {{impact: Impact}}",
+		})
+
+		value, err := bm.Expand("incident")
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, value)
+	}
+}
+
+func TestExpand_Forms(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Rolled back?": "yes"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"incident": `{{Title}} ({{rolled_back: Rolled back?|yes|no}})
+{{#if rolled_back == "yes"}}{{Rollback details}} {{Title}}{{/if}}
+{{#if impact}}{{Impact}}{{/if}}
+[[signature name="Ops"]] [[signature]]`,
+		"signature": "{{name: Your name}}, {{Team}}",
+	})
+
+	_, err := bm.Expand("incident")
+	require.NoError(t, err)
+	// Conditional branches are asked once taken, and include arguments are not asked.
+	assert.Equal(t, [][]string{
+		{"Title", "Rolled back?", "impact", "Team", "Your name"},
+		{"Rollback details"},
+	}, u.forms)
+}
+
+func TestExpand_Preview(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Your name": "Alice", "Token": "s3cr3t", "Team": "Ops"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"greeting": "Hi {{name: Your name | upper}}, {{Env|dev|*prod}} {{Token:secret}}{{#if name}} ({{Team}}){{/if}}",
+	})
+
+	value, err := bm.Expand("greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi ALICE, prod s3cr3t (Ops)", value)
+	assert.Equal(t, []string{
+		"Hi <Your name>, prod <Token>",
+		"Hi ALICE, prod •••••••• (<Team>)",
+		"Hi ALICE, prod •••••••• (<Team>)",
+		"Hi ALICE, prod •••••••• (Ops)",
+	}, u.previews)
+}
+
+func TestExpand_PreviewBuiltins(t *testing.T) {
+	defer func(readAll func() (string, error)) { clipboardReadAll = readAll }(clipboardReadAll)
+	reads := 0
+	clipboardReadAll = func() (string, error) {
+		reads++
+		return fmt.Sprintf("copy %d", reads), nil
+	}
+
+	u := &fakeUI{answers: map[string]string{"Title": "Bug"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"note": "{{Title}}: {{@clipboard}} {{@clipboard | upper}} {{$(printf main)}}",
+	})
+	bm.config.AllowedCommands = []string{"printf"}
+
+	value, err := bm.Expand("note")
+	require.NoError(t, err)
+	assert.Equal(t, "Bug: copy 1 COPY 1 main", value)
+	assert.Equal(t, []string{"<Title>: copy 1 COPY 1 <$(printf main)>", "Bug: copy 1 COPY 1 <$(printf main)>"}, u.previews)
+	assert.Equal(t, 1, reads, "built-ins are computed once per expansion")
+}
+
+func TestExpandReviewed_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	rofi := filepath.Join(dir, "rofi")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" >> " + argsPath + "\ncat > /dev/null\n" +
+		"case \"$*\" in *'-p Result'*) echo 'copy to clipboard' ;; *'-p Host'*) echo example.com ;; *) echo s3cr3t ;; esac\n"
+	require.NoError(t, os.WriteFile(rofi, []byte(script), 0700))
+
+	bm := newTestEngine(t, ui.NewRofiUI(ui.RofiConfig{Path: rofi}), map[string]string{
+		"login": "curl -u admin:{{Token:secret}} {{Host}}",
+	})
+
+	value, ok, err := bm.ExpandReviewed("login", ExpandOptions{}, "copy to clipboard")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "curl -u admin:s3cr3t example.com", value)
+
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "curl -u admin:•••••••• example.com")
+	assert.NotContains(t, string(args), "s3cr3t", "secrets never appear on the command line of Rofi")
+}
+
+func TestExpand_Answers(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Severity": "high"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"incident": "{{title: Title}} ({{Severity}}) on {{services: Services[]|api|web|db}}, {{Count:int}} users",
+	})
+
+	value, err := bm.ExpandWith("incident", ExpandOptions{
+		Answers: Answers{"title": "DB down", "services": "api\ndb", "Count": "12"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "DB down (high) on api, db, 12 users", value)
+	assert.Equal(t, [][]string{{"Severity"}}, u.forms)
+
+	_, err = bm.ExpandWith("incident", ExpandOptions{Answers: Answers{"Count": "many"}})
+	assert.EqualError(t, err, `invalid answer for "Count": must be an integer`)
+}
+
+func TestExpand_NonInteractive(t *testing.T) {
+	u := &fakeUI{}
+	bm := newTestEngine(t, u, map[string]string{
+		"incident": `{{title: Title}} ({{Severity|low|*high}}, {{Owner}}){{#if Severity == "high"}} paged {{oncall: On call}}{{/if}}`,
+	})
+
+	value, err := bm.ExpandWith("incident", ExpandOptions{
+		Answers:        Answers{"title": "DB down", "Owner": "ops", "oncall": "Alice"},
+		NonInteractive: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "DB down (high, ops) paged Alice", value)
+
+	_, err = bm.ExpandWith("incident", ExpandOptions{NonInteractive: true})
+	assert.ErrorIs(t, err, ErrMissingAnswers)
+	assert.EqualError(t, err, `missing answers: title ("Title"), Owner, oncall ("On call")`)
+	assert.Empty(t, u.forms)
+}
+
+func TestExpandReviewed(t *testing.T) {
+	u := &fakeUI{
+		answers: map[string]string{"Name": "Bob", "Edit the result": "Hello Bob!"},
+		reviews: []ui.ReviewAction{ui.ReviewEdit, ui.ReviewReanswer, ui.ReviewCancel},
+	}
+	bm := newTestEngine(t, u, map[string]string{"greeting": "Hi {{Name}}"})
+
+	_, ok, err := bm.ExpandReviewed("greeting", ExpandOptions{}, "copy to clipboard")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"Hi Bob", "Hello Bob!", "Hi Bob"}, u.reviewed)
+	assert.Equal(t, [][]string{{"Name"}, {"Name"}}, u.forms, "re-answering asks again")
+	bp, _ := bm.Get("greeting")
+	assert.Equal(t, 0, bp.Count, "discarded results are not counted")
+
+	value, ok, err := bm.ExpandReviewed("greeting", ExpandOptions{}, "copy to clipboard")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "Hi Bob", value)
+	assert.Equal(t, 1, bp.Count)
+}
+
+func TestExpandTemplate(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Name": "Bob", "Team": "ops"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"signature": "-- {{Team}}",
+		"loop":      "[[loop]]",
+	})
+
+	value, err := bm.ExpandTemplate("Hi {{Name}}\n[[signature]]", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Bob\n-- ops", value)
+	assert.Equal(t, [][]string{{"Name", "Team"}}, u.forms)
+	bp, _ := bm.Get("signature")
+	assert.Equal(t, 0, bp.Count, "included boilerplates are not counted")
+
+	_, err = bm.ExpandTemplate("[[loop]]", ExpandOptions{})
+	assert.EqualError(t, err, "include cycle: <template> -> loop -> loop")
+
+	_, err = bm.ExpandTemplate("{{#if x}}", ExpandOptions{})
+	assert.ErrorContains(t, err, "unable to parse template")
+}
+
+func TestExpand_Builtins(t *testing.T) {
+	defer func(now func() time.Time, readAll func() (string, error)) {
+		timeNow, clipboardReadAll = now, readAll
+	}(timeNow, clipboardReadAll)
+	timeNow = func() time.Time { return time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC) }
+	clipboardReadAll = func() (string, error) { return "copied text", nil }
+	t.Setenv("USER", "alice")
+
+	u := &fakeUI{}
+	bm := newTestEngine(t, u, map[string]string{
+		"note": "{{@date}} {{@time}} | due {{@date +7d}} | {{@date -1d \"Mon Jan 2\"}} {{@date}} | {{@user}} | {{@clipboard}}",
+	})
+
+	value, err := bm.Expand("note")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-01 09:30 | due 2024-03-08 | Thu Feb 29 2024-03-01 | alice | copied text", value)
+	assert.Empty(t, u.asked)
+
+	_, err = parse("{{@unknown}}")
+	assert.EqualError(t, err, "1:1: unknown built-in variable @unknown")
+}
+
+func TestExpand_Filters(t *testing.T) {
+	RegisterFilter("reverse", func(value string, args []string) (string, error) {
+		runes := []rune(value)
+		slices.Reverse(runes)
+		return string(runes), nil
+	})
+	defer delete(filters, "reverse")
+
+	u := &fakeUI{answers: map[string]string{
+		"Ticket title": "fix login page",
+		"Reviewer":     "",
+	}}
+	bm := newTestEngine(t, u, map[string]string{
+		"pr": "# {{title: Ticket title | title}}\nbranch: fix/{{title | snake}}\nlink: https://example.com/?q={{title | urlencode}}\n" +
+			"reviewer: {{Reviewer | default \"nobody\"}}\n{{title | reverse | upper}}",
+	})
+
+	value, err := bm.Expand("pr")
+	require.NoError(t, err)
+	assert.Equal(t, "# Fix Login Page\nbranch: fix/fix_login_page\nlink: https://example.com/?q=fix+login+page\nreviewer: nobody\nEGAP NIGOL XIF", value)
+	assert.Equal(t, []string{"Ticket title", "Reviewer"}, u.asked)
+}
+
+func TestExpand_IncludeCycles(t *testing.T) {
+	bm := newTestEngine(t, &fakeUI{}, map[string]string{
+		"self":  "me [[self]]",
+		"a":     "A [[b]]",
+		"b":     "B [[c]]",
+		"c":     "C [[a]]",
+		"d1":    "[[d2]]",
+		"d2":    "[[d3]]",
+		"d3":    "[[d4]]",
+		"d4":    "done",
+		"twice": "[[d4]] and [[d4]]",
+	})
+
+	_, err := bm.Expand("self")
+	assert.ErrorIs(t, err, ErrIncludeCycle)
+	assert.EqualError(t, err, "include cycle: self -> self")
+
+	_, err = bm.Expand("a")
+	assert.EqualError(t, err, "include cycle: a -> b -> c -> a")
+
+	value, err := bm.Expand("twice")
+	require.NoError(t, err)
+	assert.Equal(t, "done and done", value)
+
+	bm.config.MaxIncludeDepth = 3
+	value, err = bm.Expand("d1")
+	require.NoError(t, err)
+	assert.Equal(t, "done", value)
+
+	bm.config.MaxIncludeDepth = 2
+	_, err = bm.Expand("d1")
+	assert.ErrorIs(t, err, ErrIncludeTooDeep)
+	assert.EqualError(t, err, "too many nested includes (max 2): d1 -> d2 -> d3 -> d4")
+}
+
+func TestExpand_MultiSelect(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Affected services": "api\ndb"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"incident": "Services: {{services[]: Affected services|api|web|db}}\n{{services | bullets}}\n{{services | upper | join \" / \"}}\n" +
+			"Teams: {{Teams[]|*ops|dev|*qa}}",
+	})
+
+	value, err := bm.Expand("incident")
+	require.NoError(t, err)
+	assert.Equal(t, "Services: api, db\n- api\n- db\nAPI / DB\nTeams: ops, qa", value)
+	assert.Equal(t, []string{"Affected services", "Teams"}, u.asked)
+
+	bm.config.MultiSelectSeparator = " + "
+	u.answers["Affected services"] = "web"
+	value, err = bm.Expand("incident")
+	require.NoError(t, err)
+	assert.Equal(t, "Services: web\n- web\nWEB\nTeams: ops + qa", value)
+}
+
+func TestExpand_ChoiceSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.txt")
+	require.NoError(t, os.WriteFile(path, []byte("api\n\n  web\n"), 0600))
+
+	u := &recordingUI{fakeUI: fakeUI{answers: map[string]string{"Assignee": "bob", "Service": "web", "Branch": "main"}}}
+	bm := newTestEngine(t, u, map[string]string{
+		"ticket":       "{{Assignee|me|@boilerplate:team_members}} {{Service|@file:" + path + "}} {{Branch|@cmd:printf main\\ndev}}",
+		"team_members": "alice\nbob\n",
+		"broken":       "{{Branch|@cmd:echo oops >&2; exit 3}}",
+		"empty":        "{{Who|@boilerplate:nobody}}",
+		"denied":       "{{Branch|@cmd:sh -c \"git branch\"}}",
+		"chained":      "{{Branch|@cmd:printf main; whoami}}",
+		"slow":         "{{Branch|@cmd:sleep 5}}",
+	})
+	bm.config.AllowedCommands = []string{"printf"}
+	editBoilerplate(t, bm, "broken", func(bp *boilerplate.Boilerplate) { bp.Trusted = true })
+	editBoilerplate(t, bm, "slow", func(bp *boilerplate.Boilerplate) { bp.Trusted = true })
+
+	value, err := bm.Expand("ticket")
+	require.NoError(t, err)
+	assert.Equal(t, "bob web main", value)
+	assert.Equal(t, map[string][]string{
+		"Assignee": {"me", "alice", "bob"},
+		"Service":  {"api", "web"},
+		"Branch":   {"main", "dev"},
+	}, u.choices)
+
+	_, err = bm.Expand("broken")
+	assert.EqualError(t, err, `choices of "Branch" from @cmd:echo oops >&2; exit 3: command "echo oops >&2; exit 3": exit status 3: oops`)
+
+	_, err = bm.Expand("empty")
+	assert.EqualError(t, err, `choices of "Who" from @boilerplate:nobody: unknown referenced boilerplate "nobody"`)
+
+	// Sourced commands are run like command placeholders.
+	_, err = bm.Expand("denied")
+	assert.ErrorIs(t, err, ErrCommandNotAllowed)
+
+	u.choices = nil
+	_, err = bm.Expand("chained")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"Branch": {"main;"}}, u.choices)
+
+	bm.config.CommandTimeout = 50 * time.Millisecond
+	_, err = bm.Expand("slow")
+	assert.EqualError(t, err, `choices of "Branch" from @cmd:sleep 5: command "sleep 5" timed out after 50ms`)
+}
+
+func TestExpand_Commands(t *testing.T) {
+	bm := newTestEngine(t, &fakeUI{}, map[string]string{
+		"branch":  "{{$(printf main) | upper}}",
+		"chained": "{{$(echo a; echo b)}}",
+		"denied":  "{{$(whoami)}}",
+		"trusted": "{{$(echo a; echo b)}}",
+		"slow":    "{{$(sleep 5)}}",
+	})
+	bm.config.AllowedCommands = []string{"printf", "echo"}
+	editBoilerplate(t, bm, "trusted", func(bp *boilerplate.Boilerplate) { bp.Trusted = true })
+	editBoilerplate(t, bm, "slow", func(bp *boilerplate.Boilerplate) { bp.Trusted = true })
+
+	value, err := bm.Expand("branch")
+	require.NoError(t, err)
+	assert.Equal(t, "MAIN", value)
+
+	// Allowed commands are not run by the shell
+	value, err = bm.Expand("chained")
+	require.NoError(t, err)
+	assert.Equal(t, "a; echo b", value)
+
+	value, err = bm.Expand("trusted")
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb", value)
+
+	_, err = bm.Expand("denied")
+	assert.ErrorIs(t, err, ErrCommandNotAllowed)
+
+	bm.config.CommandTimeout = 50 * time.Millisecond
+	_, err = bm.Expand("slow")
+	assert.EqualError(t, err, `command "sleep 5" timed out after 50ms`)
+}
+
+func TestExpand_Env(t *testing.T) {
+	t.Setenv("JIRA_PROJECT", "OPS")
+	t.Setenv("EZBP_EMPTY", "")
+
+	u := &fakeUI{answers: map[string]string{"Your team": "sre"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"ticket": "{{env:JIRA_PROJECT}}-{{env:JIRA_PROJECT ?? Project | lower}} [{{env:EZBP_UNSET}}] " +
+			"{{env:EZBP_EMPTY ?? team: Your team}} {{env:EZBP_UNSET ?? team: Your team}} {{team}} {{env:EZBP_UNSET ?? Region=eu}}",
+	})
+
+	value, err := bm.Expand("ticket")
+	require.NoError(t, err)
+	assert.Equal(t, "OPS-ops [] sre sre sre eu", value)
+	assert.Equal(t, []string{"Your team", "Region"}, u.asked)
+}
+
+func TestExpand_TypedPrompts(t *testing.T) {
+	u := &sequenceUI{answers: []string{"many", "3", "ops-1", "OPS-1"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"ticket": `{{Count:int}} {{Ticket:/^[A-Z]+-\d+$/}} {{Due:date=2024-03-01}}`,
+	})
+
+	value, err := bm.Expand("ticket")
+	require.NoError(t, err)
+	assert.Equal(t, "3 OPS-1 2024-03-01", value)
+	assert.Equal(t, []string{
+		"Count",
+		"Count (must be an integer)",
+		"Ticket",
+		`Ticket (must match /^[A-Z]+-\d+$/)`,
+		"Due",
+	}, u.asked)
+	assert.Equal(t, []string{"", "many", "", "ops-1", "2024-03-01"}, u.defaults)
+}
+
+func TestExpand_MultilinePrompts(t *testing.T) {
+	u := &sequenceUI{answers: []string{"login fails", "panic: oops\n\tmain.go:12"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"bug": "# {{Title}}\n\n```\n{{Stack trace:text}}\n```",
+	})
+
+	value, err := bm.Expand("bug")
+	require.NoError(t, err)
+	assert.Equal(t, "# login fails\n\n```\npanic: oops\n\tmain.go:12\n```", value)
+	assert.Equal(t, []bool{false, true}, u.multiline)
+}
+
+func TestExpand_SecretPrompts(t *testing.T) {
+	u := &sequenceUI{answers: []string{"alice", "s3cr3t"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"login":  "{{User}}:[[token]]",
+		"token":  "{{Token:secret}}",
+		"public": "{{User}}",
+	})
+
+	value, err := bm.Expand("login")
+	require.NoError(t, err)
+	assert.Equal(t, "alice:s3cr3t", value)
+	assert.Equal(t, []bool{false, true}, u.secret)
+
+	secret, err := bm.HasSecrets("login")
+	require.NoError(t, err)
+	assert.True(t, secret)
+
+	secret, err = bm.HasSecrets("public")
+	require.NoError(t, err)
+	assert.False(t, secret)
+}
+
+// sequenceUI is a fakeUI answering prompts in order, whatever their label.
+// Once all the answers are used, prompts get their default value.
+type sequenceUI struct {
+	fakeUI
+	answers   []string
+	defaults  []string
+	multiline []bool
+	secret    []bool
+}
+
+func (u *sequenceUI) Prompt(prompt string, opts ui.PromptOptions) (string, error) {
+	u.asked = append(u.asked, prompt)
+	u.defaults = append(u.defaults, opts.Default)
+	u.multiline = append(u.multiline, opts.Multiline)
+	u.secret = append(u.secret, opts.Secret)
+	if len(u.answers) == 0 {
+		return opts.Default, nil
+	}
+	answer := u.answers[0]
+	u.answers = u.answers[1:]
+	return answer, nil
+}
+
+func (u *sequenceUI) Form(fields []ui.Field, preview ui.Preview) error {
+	return u.form(u, fields, preview)
+}
+
+// recordingUI is a fakeUI recording the choices it is given.
+type recordingUI struct {
+	fakeUI
+	choices map[string][]string
+}
+
+func (u *recordingUI) Select(prompt string, choices []string, defaultValue string) (string, error) {
+	if u.choices == nil {
+		u.choices = make(map[string][]string)
+	}
+	u.choices[prompt] = choices
+	return u.fakeUI.Select(prompt, choices, defaultValue)
+}
+
+func (u *recordingUI) Form(fields []ui.Field, preview ui.Preview) error {
+	return u.form(u, fields, preview)
+}
+
+func TestExpand_IncludeArguments(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Your name": "Alice", "Tone": "casual"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"mail":      "Hi.\n[[signature name=\"Ops team\" tone=formal]]\n[[signature]]\n{{name}}",
+		"signature": "-- {{name: Your name}} ({{tone: Tone}}[[suffix]])",
+		"suffix":    ", {{tone}}",
+	})
+
+	value, err := bm.Expand("mail")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi.\n-- Ops team (formal, formal)\n-- Alice (casual, casual)\nAlice", value)
+	assert.Equal(t, []string{"Your name", "Tone"}, u.asked)
+}
+
+func TestExpand_Raw(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Chart": "api"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"values": "image: {{ .Values.image }} [[chart]]",
+		"chart":  "# {{Chart}}\n[[values]]",
+	})
+	editBoilerplate(t, bm, "values", func(bp *boilerplate.Boilerplate) { bp.Raw = true })
+
+	value, err := bm.Expand("values")
+	require.NoError(t, err)
+	assert.Equal(t, "image: {{ .Values.image }} [[chart]]", value)
+
+	value, err = bm.Expand("chart")
+	require.NoError(t, err)
+	assert.Equal(t, "# api\nimage: {{ .Values.image }} [[chart]]", value)
+
+	assert.ErrorIs(t, bm.EditBoilerplate(&boilerplate.Boilerplate{Name: "unknown", Value: "x", Raw: true}), ErrBoilerplateUnknown)
+}
+
+func TestExpand_Errors(t *testing.T) {
+	bm := newTestEngine(t, &fakeUI{}, map[string]string{
+		"missing": "[[unknown]]",
+		"broken":  "Hello {{#if name}}",
+	})
+
+	_, err := bm.Expand("nope")
+	assert.EqualError(t, err, `unknown boilerplate "nope"`)
+
+	_, err = bm.Expand("missing")
+	assert.EqualError(t, err, `unknown referenced boilerplate "unknown"`)
+
+	_, err = bm.Expand("broken")
+	assert.EqualError(t, err, `unable to parse boilerplate "broken": 1:7: unclosed {{#if}} block`)
+}
@@ -0,0 +1,413 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// expansion holds the state of a single expansion, shared by the expanded
+// boilerplate and all the boilerplates it includes.
+type expansion struct {
+	bm *Engine
+	// answers holds the value of each variable answered so far.
+	answers map[string]string
+	// defs holds the first prompt or choice defining each variable.
+	defs map[string]node
+	// parsed caches the parsed boilerplates, by name.
+	parsed map[string][]node
+	// now is the time of the expansion, used by date built-ins.
+	now time.Time
+	// includes is the chain of boilerplates being rendered, starting with the expanded one.
+	includes []string
+	// scopes holds the arguments of the includes being rendered, innermost last.
+	scopes []map[string]string
+	// resolved caches the values of built-ins and commands, so that previews and the
+	// final rendering compute them once and show the same values.
+	resolved map[string]string
+	// root holds the nodes of the expanded boilerplate, rendered in previews.
+	root []node
+	// pending, if set, makes render write a preview: it is given the label of the
+	// variables not answered yet, and the commands, instead of asking or running them.
+	pending func(label string) string
+	// nonInteractive answers the variables with their default value instead of asking them,
+	// recording those without default in missing.
+	nonInteractive bool
+	missing        []string
+}
+
+// newExpansion starts the expansion of the boilerplate called name.
+func (bm *Engine) newExpansion(name string) *expansion {
+	return &expansion{
+		bm:       bm,
+		includes: []string{name},
+		answers:  make(map[string]string),
+		defs:     make(map[string]node),
+		parsed:   make(map[string][]node),
+		resolved: make(map[string]string),
+		now:      timeNow(),
+	}
+}
+
+// templateName stands for templates expanded with ExpandTemplate in include chains.
+const templateName = "<template>"
+
+// parseTemplate parses a template expanded with ExpandTemplate.
+func parseTemplate(template string) ([]node, error) {
+	nodes, err := parse(template)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse template: %w", err)
+	}
+	return nodes, nil
+}
+
+// expand asks the variables of nodes, the parsed expanded boilerplate, and renders them.
+func (ex *expansion) expand(nodes []node, opts ExpandOptions) (string, error) {
+	values, err := ex.expandParts([][]node{nodes}, opts)
+	if err != nil {
+		return "", err
+	}
+	return values[0], nil
+}
+
+// expandParts expands several parts of a document at once, as expand does: their variables
+// are asked together, and each answer is shared by all the parts. It returns the
+// rendering of each part.
+func (ex *expansion) expandParts(parts [][]node, opts ExpandOptions) ([]string, error) {
+	var all []node
+	for i, nodes := range parts {
+		if i > 0 {
+			all = append(all, &textNode{text: "\n"})
+		}
+		all = append(all, nodes...)
+	}
+
+	ex.define(all, map[string]bool{ex.includes[0]: true})
+	ex.root = all
+	ex.nonInteractive = opts.NonInteractive
+	if err := ex.preanswer(opts.Answers); err != nil {
+		return nil, err
+	}
+	if err := ex.ask(ex.collect(all)); err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(parts))
+	for _, nodes := range parts {
+		var out strings.Builder
+		if err := ex.render(nodes, &out); err != nil {
+			return nil, err
+		}
+		values = append(values, out.String())
+	}
+	if len(ex.missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingAnswers, strings.Join(ex.missing, ", "))
+	}
+	return values, nil
+}
+
+// hasSecrets reports whether nodes, or the boilerplates they include, define secret prompts.
+func (ex *expansion) hasSecrets(nodes []node) bool {
+	ex.define(nodes, map[string]bool{ex.includes[0]: true})
+	return slices.ContainsFunc(slices.Collect(maps.Values(ex.defs)), isSecret)
+}
+
+// parseBoilerplate parses the boilerplate called name, caching the result.
+// Raw boilerplates are not parsed and give a single text node. Scaffolds can't be parsed
+// as text, see parseScaffold.
+func (ex *expansion) parseBoilerplate(name string) ([]node, error) {
+	if nodes, found := ex.parsed[name]; found {
+		return nodes, nil
+	}
+
+	bp, found := ex.bm.boilerplates[name]
+	if !found {
+		return nil, fmt.Errorf("unknown referenced boilerplate %q", name)
+	}
+	if bp.Scaffold {
+		return nil, fmt.Errorf("boilerplate %q is a scaffold, which can't be expanded as text", name)
+	}
+
+	nodes := []node{&textNode{text: bp.Value}}
+	if !bp.Raw {
+		var err error
+		if nodes, err = parse(bp.Value); err != nil {
+			return nil, fmt.Errorf("unable to parse boilerplate %q: %w", name, err)
+		}
+		if bp.Trusted {
+			trust(nodes)
+		}
+	}
+	ex.parsed[name] = nodes
+
+	return nodes, nil
+}
+
+// trust marks the commands of nodes as trusted, including those of choice sources.
+func trust(nodes []node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *commandNode:
+			n.trusted = true
+		case *choiceNode:
+			for i := range n.sources {
+				n.sources[i].trusted = true
+			}
+		case *ifNode:
+			trust(n.then)
+			trust(n.els)
+		}
+	}
+}
+
+// define records the variables defined by nodes and by the boilerplates they include,
+// so that a variable referenced before its definition is asked with the right label.
+// Explicitly named definitions take precedence over bare references.
+func (ex *expansion) define(nodes []node, visited map[string]bool) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *promptNode:
+			ex.defineVariable(n.name, n)
+		case *choiceNode:
+			ex.defineVariable(n.name, n)
+		case *envNode:
+			if n.fallback != nil {
+				ex.defineVariable(n.fallback.name, n.fallback)
+			}
+		case *ifNode:
+			ex.define(n.then, visited)
+			ex.define(n.els, visited)
+		case *includeNode:
+			if visited[n.name] {
+				continue
+			}
+			visited[n.name] = true
+			// Unknown or invalid boilerplates are reported during rendering.
+			if included, err := ex.parseBoilerplate(n.name); err == nil {
+				ex.define(included, visited)
+			}
+		}
+	}
+}
+
+func (ex *expansion) defineVariable(name string, n node) {
+	if def, found := ex.defs[name]; found && !isReference(def) {
+		return
+	}
+	ex.defs[name] = n
+}
+
+// isReference reports whether n is a bare {{name}} placeholder, which only
+// defines its variable when no other placeholder does.
+func isReference(n node) bool {
+	p, ok := n.(*promptNode)
+	return ok && p.name == p.label
+}
+
+// render evaluates a list of nodes and writes the result to out.
+// Each variable is asked once through the engine's UI, and included
+// boilerplates are parsed and rendered recursively. The variables of a
+// conditional branch are asked together in a form when the branch is taken.
+func (ex *expansion) render(nodes []node, out *strings.Builder) error {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *textNode:
+			out.WriteString(n.text)
+
+		case *promptNode:
+			if err := ex.writeVariable(out, n.name, n.filters); err != nil {
+				return err
+			}
+
+		case *choiceNode:
+			if err := ex.writeVariable(out, n.name, n.filters); err != nil {
+				return err
+			}
+
+		case *commandNode:
+			if ex.pending != nil {
+				out.WriteString(ex.pending("$(" + n.command + ")"))
+				continue
+			}
+			key := fmt.Sprintf("$(%s) trusted=%t", n.command, n.trusted)
+			value, err := ex.resolve(key, func() (string, error) { return ex.runCommand(n.command, n.trusted) })
+			if err != nil {
+				return err
+			}
+			if err := writeFiltered(out, value, n.filters); err != nil {
+				return err
+			}
+
+		case *envNode:
+			value := os.Getenv(n.name)
+			if value == "" && n.fallback != nil {
+				if err := ex.writeVariable(out, n.fallback.name, n.filters); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := writeFiltered(out, value, n.filters); err != nil {
+				return err
+			}
+
+		case *builtinNode:
+			value, err := ex.resolve("@"+n.name+" "+n.arg, func() (string, error) { return builtins[n.name](ex, n.arg) })
+			if err != nil {
+				return fmt.Errorf("@%s: %w", n.name, err)
+			}
+			if err := writeFiltered(out, value, n.filters); err != nil {
+				return err
+			}
+
+		case *includeNode:
+			if err := ex.enter(n.name); err != nil {
+				return err
+			}
+			included, err := ex.parseBoilerplate(n.name)
+			if err != nil {
+				return err
+			}
+			ex.scopes = append(ex.scopes, n.args)
+			if err := ex.render(included, out); err != nil {
+				return err
+			}
+			ex.scopes = ex.scopes[:len(ex.scopes)-1]
+			ex.includes = ex.includes[:len(ex.includes)-1]
+
+		case *ifNode:
+			ok, err := ex.evalCondition(n.cond)
+			if err != nil {
+				return err
+			}
+			branch := n.els
+			if ok {
+				branch = n.then
+			}
+			if err := ex.ask(ex.collect(branch)); err != nil {
+				return err
+			}
+			if err := ex.render(branch, out); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unexpected node %T", n)
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the value computed by compute for key, computing it once per expansion.
+// Errors are not cached.
+func (ex *expansion) resolve(key string, compute func() (string, error)) (string, error) {
+	if value, found := ex.resolved[key]; found {
+		return value, nil
+	}
+	value, err := compute()
+	if err != nil {
+		return "", err
+	}
+	ex.resolved[key] = value
+	return value, nil
+}
+
+// enter pushes an included boilerplate on the include chain.
+// It fails if the boilerplate is already being rendered, or if the chain
+// gets longer than the configured maximum depth.
+func (ex *expansion) enter(name string) error {
+	chain := strings.Join(append(slices.Clone(ex.includes), name), " -> ")
+	if slices.Contains(ex.includes, name) {
+		return fmt.Errorf("%w: %s", ErrIncludeCycle, chain)
+	}
+
+	maxDepth := ex.bm.config.MaxIncludeDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxIncludeDepth
+	}
+	if len(ex.includes) > maxDepth {
+		return fmt.Errorf("%w (max %d): %s", ErrIncludeTooDeep, maxDepth, chain)
+	}
+
+	ex.includes = append(ex.includes, name)
+	return nil
+}
+
+// writeVariable writes the value of a variable through filters.
+// A list is joined with the configured separator, unless the filters format it themselves.
+func (ex *expansion) writeVariable(out *strings.Builder, name string, calls []filterCall) error {
+	if _, found := ex.lookup(name); !found && ex.pending != nil {
+		out.WriteString(ex.pending(ex.label(name)))
+		return nil
+	}
+
+	value, err := ex.variable(name)
+	if err != nil {
+		return err
+	}
+
+	if def, ok := ex.defs[name].(*choiceNode); ok && def.multi {
+		formatted := slices.ContainsFunc(calls, func(call filterCall) bool {
+			return call.name == "join" || call.name == "bullets"
+		})
+		if !formatted {
+			separator := ex.bm.config.MultiSelectSeparator
+			if separator == "" {
+				separator = defaultListSeparator
+			}
+			calls = append([]filterCall{{name: "join", args: []string{separator}}}, calls...)
+		}
+	}
+
+	return writeFiltered(out, value, calls)
+}
+
+// writeFiltered applies filters to a value and writes the result to out.
+func writeFiltered(out *strings.Builder, value string, calls []filterCall) error {
+	value, err := applyFilters(value, calls)
+	if err != nil {
+		return err
+	}
+	out.WriteString(value)
+	return nil
+}
+
+// variable returns the value of the variable called name, asking the user
+// through its definition the first time it is needed, if it was not asked in a form.
+// Variables not answered yet are empty in previews.
+func (ex *expansion) variable(name string) (string, error) {
+	if value, found := ex.lookup(name); found || ex.pending != nil {
+		return value, nil
+	}
+
+	if err := ex.ask([]string{name}); err != nil {
+		return "", err
+	}
+	return ex.answers[name], nil
+}
+
+// lookup returns the value of the variable called name, if it is known:
+// arguments of the enclosing includes take precedence over answers.
+func (ex *expansion) lookup(name string) (string, bool) {
+	for i := len(ex.scopes) - 1; i >= 0; i-- {
+		if value, found := ex.scopes[i][name]; found {
+			return value, true
+		}
+	}
+	value, found := ex.answers[name]
+	return value, found
+}
+
+// label returns the label used to ask for the variable called name.
+func (ex *expansion) label(name string) string {
+	switch def := ex.defs[name].(type) {
+	case *promptNode:
+		return def.label
+	case *choiceNode:
+		return def.label
+	}
+	return name
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Filter transforms the value of a placeholder, as in "{{title | upper}}".
+// args holds the arguments following the filter name, e.g. ["x"] for `default "x"`.
+type Filter func(value string, args []string) (string, error)
+
+// filters holds the registered filters, by name.
+var filters = map[string]Filter{
+	"upper":     noArgFilter(strings.ToUpper),
+	"lower":     noArgFilter(strings.ToLower),
+	"title":     noArgFilter(titleCase),
+	"trim":      noArgFilter(strings.TrimSpace),
+	"snake":     noArgFilter(func(s string) string { return strings.ToLower(strings.Join(words(s), "_")) }),
+	"kebab":     noArgFilter(func(s string) string { return strings.ToLower(strings.Join(words(s), "-")) }),
+	"camel":     noArgFilter(camelCase),
+	"urlencode": noArgFilter(url.QueryEscape),
+	"json":      noArgFilter(jsonString),
+	"default":   defaultFilter,
+	"join":      joinFilter,
+	"bullets":   bulletsFilter,
+}
+
+// listSeparator separates the items of a list value, such as the answer of a multi-select.
+const listSeparator = "\n"
+
+// defaultListSeparator joins the items of a list value written without a join or bullets filter,
+// unless configured otherwise.
+const defaultListSeparator = ", "
+
+// RegisterFilter makes a filter available in templates under the given name,
+// replacing any filter previously registered with that name.
+// It must be called before the templates using the filter are expanded.
+func RegisterFilter(name string, filter Filter) {
+	filters[name] = filter
+}
+
+// filterCall is a filter applied to a placeholder, with its arguments.
+type filterCall struct {
+	name string
+	args []string
+}
+
+// applyFilters applies a chain of filters to a value.
+func applyFilters(value string, calls []filterCall) (string, error) {
+	for _, call := range calls {
+		filter, found := filters[call.name]
+		if !found {
+			return "", fmt.Errorf("unknown filter %q", call.name)
+		}
+		var err error
+		if value, err = filter(value, call.args); err != nil {
+			return "", fmt.Errorf("filter %q: %w", call.name, err)
+		}
+	}
+	return value, nil
+}
+
+// noArgFilter turns a string function into a filter taking no argument.
+func noArgFilter(fn func(string) string) Filter {
+	return func(value string, args []string) (string, error) {
+		if len(args) > 0 {
+			return "", fmt.Errorf("unexpected arguments %q", args)
+		}
+		return fn(value), nil
+	}
+}
+
+// defaultFilter replaces an empty value by its argument.
+func defaultFilter(value string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expecting 1 argument, got %d", len(args))
+	}
+	if strings.TrimSpace(value) == "" {
+		return args[0], nil
+	}
+	return value, nil
+}
+
+// joinFilter joins the items of a list value with its argument, as in `join " / "`.
+func joinFilter(value string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expecting 1 argument, got %d", len(args))
+	}
+	return strings.ReplaceAll(value, listSeparator, args[0]), nil
+}
+
+// bulletsFilter writes the items of a list value one per line, each preceded
+// by its argument or by "- " when there is none.
+func bulletsFilter(value string, args []string) (string, error) {
+	bullet := "- "
+	switch len(args) {
+	case 0:
+	case 1:
+		bullet = args[0]
+	default:
+		return "", fmt.Errorf("expecting at most 1 argument, got %d", len(args))
+	}
+	if value == "" {
+		return "", nil
+	}
+	return bullet + strings.ReplaceAll(value, listSeparator, listSeparator+bullet), nil
+}
+
+// words splits a string into words, on non-alphanumeric characters and on
+// case changes ("HTTPServer error" gives "HTTP", "Server" and "error").
+func words(s string) []string {
+	var (
+		result []string
+		word   []rune
+	)
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				result = append(result, string(word))
+				word = nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextIsLower {
+				result = append(result, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		result = append(result, string(word))
+	}
+	return result
+}
+
+// titleCase upper-cases the first letter of each word, keeping separators.
+func titleCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '-' || runes[i-1] == '_' {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// camelCase joins the words of s, upper-casing the first letter of all but the first one.
+func camelCase(s string) string {
+	var sb strings.Builder
+	for i, word := range words(s) {
+		word = strings.ToLower(word)
+		if i > 0 {
+			word = titleCase(word)
+		}
+		sb.WriteString(word)
+	}
+	return sb.String()
+}
+
+// jsonString encodes s as a JSON string, quotes included.
+func jsonString(s string) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // strings always encode successfully
+	return strings.TrimSuffix(sb.String(), "\n")
+}
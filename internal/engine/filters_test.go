@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilters(t *testing.T) {
+	for _, tc := range []struct {
+		filter string
+		args   []string
+		input  string
+		want   string
+	}{
+		{"upper", nil, "Fix login", "FIX LOGIN"},
+		{"lower", nil, "Fix Login", "fix login"},
+		{"title", nil, "fix the login-page bug", "Fix The Login-Page Bug"},
+		{"trim", nil, "  padded \n", "padded"},
+		{"snake", nil, "Fix the HTTPServer login", "fix_the_http_server_login"},
+		{"kebab", nil, "fixTheLogin page", "fix-the-login-page"},
+		{"camel", nil, "fix the login", "fixTheLogin"},
+		{"urlencode", nil, "a b&c=d", "a+b%26c%3Dd"},
+		{"json", nil, "say \"hi\" <now>\n", `"say \"hi\" <now>\n"`},
+		{"default", []string{"none"}, " ", "none"},
+		{"default", []string{"none"}, "value", "value"},
+		{"join", []string{" / "}, "api\nweb", "api / web"},
+		{"bullets", nil, "api\nweb", "- api\n- web"},
+		{"bullets", []string{"* "}, "api", "* api"},
+		{"bullets", nil, "", ""},
+	} {
+		got, err := filters[tc.filter](tc.input, tc.args)
+		require.NoError(t, err, tc.filter)
+		assert.Equal(t, tc.want, got, tc.filter)
+	}
+
+	_, err := filters["upper"]("a", []string{"b"})
+	assert.EqualError(t, err, `unexpected arguments ["b"]`)
+
+	_, err = filters["default"]("a", nil)
+	assert.EqualError(t, err, "expecting 1 argument, got 0")
+}
+
+func TestParse_Filters(t *testing.T) {
+	nodes, err := parse(`{{title: Title | trim | default "a | b"}}{{Case|upper|lower | upper}}{{@date | json}}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []node{
+		&promptNode{pos: 0, name: "title", label: "Title", filters: []filterCall{
+			{name: "trim", args: []string{}},
+			{name: "default", args: []string{"a | b"}},
+		}},
+		&choiceNode{pos: 41, name: "Case", label: "Case", choices: []string{"upper", "lower"}, filters: []filterCall{
+			{name: "upper", args: []string{}},
+		}},
+		&builtinNode{pos: 69, name: "date", filters: []filterCall{{name: "json", args: []string{}}}},
+	}, nodes)
+
+	// Choices which aren't all filters are kept as choices.
+	nodes, err = parse(`{{Output format | json | csv}}{{Case | upper | camel | sentence | trim}}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []node{
+		&choiceNode{pos: 0, name: "Output format", label: "Output format", choices: []string{"json", "csv"}},
+		&choiceNode{pos: 30, name: "Case", label: "Case", choices: []string{"upper", "camel", "sentence"}, filters: []filterCall{
+			{name: "trim", args: []string{}},
+		}},
+	}, nodes)
+}
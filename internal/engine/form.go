@@ -0,0 +1,275 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/driquet/ezbp/internal/ui"
+)
+
+// collector lists the variables needed to render nodes, see expansion.collect.
+type collector struct {
+	ex    *expansion
+	names []string
+	seen  map[string]bool
+	// includes and scopes follow the includes being walked, as in expansion.
+	includes []string
+	scopes   []map[string]string
+}
+
+// collect lists the variables to ask for rendering nodes, in order of appearance, so that
+// they can be asked at once in a form. The variables of conditional branches are left out,
+// as they are only needed if their branch is taken, but the variables of conditions are kept.
+// Variables already answered, or given by the arguments of an include, are left out too.
+func (ex *expansion) collect(nodes []node) []string {
+	c := &collector{
+		ex:       ex,
+		seen:     make(map[string]bool),
+		includes: slices.Clone(ex.includes),
+		scopes:   slices.Clone(ex.scopes),
+	}
+	c.walk(nodes)
+	return c.names
+}
+
+func (c *collector) walk(nodes []node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *promptNode:
+			c.add(n.name)
+		case *choiceNode:
+			c.add(n.name)
+		case *envNode:
+			if n.fallback != nil && os.Getenv(n.name) == "" {
+				c.add(n.fallback.name)
+			}
+		case *ifNode:
+			c.add(n.cond.left.variable)
+			c.add(n.cond.right.variable)
+		case *includeNode:
+			// Cycles and unknown or invalid boilerplates are reported during rendering.
+			if slices.Contains(c.includes, n.name) {
+				continue
+			}
+			included, err := c.ex.parseBoilerplate(n.name)
+			if err != nil {
+				continue
+			}
+			c.includes = append(c.includes, n.name)
+			c.scopes = append(c.scopes, n.args)
+			c.walk(included)
+			c.scopes = c.scopes[:len(c.scopes)-1]
+			c.includes = c.includes[:len(c.includes)-1]
+		}
+	}
+}
+
+func (c *collector) add(name string) {
+	if name == "" || c.seen[name] {
+		return
+	}
+	for _, scope := range c.scopes {
+		if _, found := scope[name]; found {
+			return
+		}
+	}
+	if _, found := c.ex.answers[name]; found {
+		return
+	}
+	c.seen[name] = true
+	c.names = append(c.names, name)
+}
+
+// ask asks the variables called names which are not answered yet, in a single form.
+// Nothing is asked while rendering a preview.
+func (ex *expansion) ask(names []string) error {
+	if ex.pending != nil {
+		return nil
+	}
+
+	var (
+		asked  []string
+		fields []ui.Field
+	)
+	for _, name := range names {
+		if _, found := ex.answers[name]; found {
+			continue
+		}
+		field, err := ex.field(name)
+		if err != nil {
+			return err
+		}
+		asked = append(asked, name)
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if ex.nonInteractive {
+		return ex.answerDefaults(asked, fields)
+	}
+	if err := ex.bm.ui.Form(fields, ex.preview(asked, fields)); err != nil {
+		return err
+	}
+
+	for i, field := range fields {
+		if field.Multiple {
+			ex.answers[asked[i]] = strings.Join(*field.Values, listSeparator)
+		} else {
+			ex.answers[asked[i]] = *field.Value
+		}
+	}
+	return nil
+}
+
+// preanswer records answers given before the expansion, validating those of typed prompts.
+func (ex *expansion) preanswer(answers Answers) error {
+	for name, value := range answers {
+		if def, ok := ex.defs[name].(*promptNode); ok && def.typ != nil {
+			if err := def.typ.validate(value); err != nil {
+				return fmt.Errorf("invalid answer for %q: %w", name, err)
+			}
+		}
+		ex.answers[name] = value
+	}
+	return nil
+}
+
+// answerDefaults answers the variables called names with the default value of their field.
+// Variables without default are answered with an empty value, and recorded as missing.
+func (ex *expansion) answerDefaults(names []string, fields []ui.Field) error {
+	for i, field := range fields {
+		name := names[i]
+		value := *field.Value
+		if field.Multiple {
+			value = strings.Join(*field.Values, listSeparator)
+		}
+
+		if value == "" {
+			missing := name
+			if field.Prompt != name {
+				missing = fmt.Sprintf("%s (%q)", name, field.Prompt)
+			}
+			ex.missing = append(ex.missing, missing)
+		} else if field.Validate != nil {
+			if err := field.Validate(value); err != nil {
+				if field.Secret {
+					return fmt.Errorf("%q: invalid default value: %w", name, err)
+				}
+				return fmt.Errorf("%q: invalid default value %q: %w", name, value, err)
+			}
+		}
+		ex.answers[name] = value
+	}
+	return nil
+}
+
+// field builds the form field asking the variable called name, from its definition.
+// Variables without definition, such as those only used in conditions, are asked by name.
+func (ex *expansion) field(name string) (ui.Field, error) {
+	var value string
+	field := ui.Field{Prompt: ex.label(name), Value: &value}
+
+	switch def := ex.defs[name].(type) {
+	case *choiceNode:
+		choices, err := ex.choices(def)
+		if err != nil {
+			return ui.Field{}, err
+		}
+		field.Choices = choices
+		if !def.multi {
+			value = def.defaultValue
+			break
+		}
+		var defaults []string
+		if def.defaultValue != "" {
+			defaults = strings.Split(def.defaultValue, listSeparator)
+		}
+		field.Multiple = true
+		field.Values = &defaults
+
+	case *promptNode:
+		value = def.defaultValue
+		if def.typ != nil {
+			field.Validate = def.typ.validate
+			field.Multiline = def.typ.name == "text"
+			field.Secret = def.typ.name == "secret"
+		}
+	}
+
+	return field, nil
+}
+
+// secretMask replaces the answers of secret prompts in previews and reviews.
+const secretMask = "••••••••"
+
+// preview returns the preview of the expanded boilerplate while the variables called names
+// are being asked with fields. Variables answered before are filled in, as well as fields
+// which are not empty, but the answers of secret prompts are masked.
+func (ex *expansion) preview(names []string, fields []ui.Field) ui.Preview {
+	if ex.root == nil {
+		return nil
+	}
+
+	return func(pending func(label string) string) string {
+		pv := *ex
+		pv.pending = pending
+		pv.includes = slices.Clone(ex.includes[:1])
+		pv.scopes = nil
+		pv.answers = make(map[string]string, len(ex.answers)+len(fields))
+		for name, value := range ex.answers {
+			pv.answers[name] = value
+		}
+		for i, field := range fields {
+			value := *field.Value
+			if field.Multiple {
+				value = strings.Join(*field.Values, listSeparator)
+			}
+			if value != "" {
+				pv.answers[names[i]] = value
+			}
+		}
+		for name := range pv.answers {
+			if isSecret(ex.defs[name]) {
+				pv.answers[name] = secretMask
+			}
+		}
+
+		var out strings.Builder
+		if err := pv.render(ex.root, &out); err != nil {
+			fmt.Fprintf(&out, "\n\n%v", err)
+		}
+		return out.String()
+	}
+}
+
+// secrets returns the non-empty answers of the secret prompts.
+func (ex *expansion) secrets() []string {
+	var secrets []string
+	for name, value := range ex.answers {
+		if value != "" && isSecret(ex.defs[name]) {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// maskSecrets replaces the secrets found in value with secretMask, longest first
+// so that a secret containing another one is masked as a whole.
+func maskSecrets(value string, secrets []string) string {
+	secrets = slices.Clone(secrets)
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+	for _, secret := range secrets {
+		value = strings.ReplaceAll(value, secret, secretMask)
+	}
+	return value
+}
+
+// isSecret reports whether n is a secret prompt, as in "{{Token:secret}}".
+func isSecret(n node) bool {
+	p, ok := n.(*promptNode)
+	return ok && p.typ != nil && p.typ.name == "secret"
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Merged is the expansion of a boilerplate for a row of a dataset, see Merge.
+type Merged struct {
+	// Row holds the answers of the row.
+	Row   Answers
+	Value string
+	// Path is the expanded file name template, if any.
+	Path string
+}
+
+// Merge expands a boilerplate once for each row of a dataset, as in a mail merge: each row
+// answers the variables named by its columns, see LoadDataset. The variables no row answers
+// are asked once, when first needed, and their answers are shared by all the rows.
+// opts.Answers apply to all the rows, which take precedence over them.
+//
+// If pathTemplate is not empty, it is expanded along with each row, as a file name for its
+// expansion: it can refer to the variables of the boilerplate, and must give distinct
+// file paths, relative and inside the current directory.
+// The usage count of the boilerplate is incremented once, unless opts.DryRun is set.
+func (bm *Engine) Merge(name string, rows []Answers, pathTemplate string, opts ExpandOptions) ([]Merged, error) {
+	if _, found := bm.boilerplates[name]; !found {
+		return nil, fmt.Errorf("unknown boilerplate %q", name)
+	}
+
+	var pathNodes []node
+	if pathTemplate != "" {
+		var err error
+		if pathNodes, err = parse(pathTemplate); err != nil {
+			return nil, fmt.Errorf("unable to parse file name template: %w", err)
+		}
+	}
+
+	shared := maps.Clone(opts.Answers)
+	if shared == nil {
+		shared = Answers{}
+	}
+	paths := make(map[string]int)
+	merged := make([]Merged, 0, len(rows))
+	for i, row := range rows {
+		ex := bm.newExpansion(name)
+		nodes, err := ex.parseBoilerplate(name)
+		if err != nil {
+			return nil, err
+		}
+
+		rowOpts := opts
+		rowOpts.Answers = maps.Clone(shared)
+		maps.Copy(rowOpts.Answers, row)
+		parts := [][]node{nodes}
+		if pathNodes != nil {
+			parts = append(parts, pathNodes)
+		}
+		values, err := ex.expandParts(parts, rowOpts)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+
+		// The answers asked for this row are reused by the next ones.
+		for name, value := range ex.answers {
+			if _, found := row[name]; !found {
+				shared[name] = value
+			}
+		}
+
+		m := Merged{Row: row, Value: values[0]}
+		if pathNodes != nil {
+			m.Path = strings.TrimSpace(values[1])
+			if m.Path == "" || isDir(m.Path) {
+				return nil, fmt.Errorf("row %d: invalid file name %q", i+1, m.Path)
+			}
+			if err := checkPath(m.Path); err != nil {
+				return nil, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			if previous, found := paths[m.Path]; found {
+				return nil, fmt.Errorf("rows %d and %d have the same file name %q", previous, i+1, m.Path)
+			}
+			paths[m.Path] = i + 1
+		}
+		merged = append(merged, m)
+	}
+
+	if !opts.DryRun {
+		bm.RecordUsage(name)
+	}
+
+	return merged, nil
+}
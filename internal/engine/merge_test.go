@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Sender": "Bob", "Reason": "late"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"invite":    `Hi {{name: Name}}, {{#if vip == "yes"}}{{Reason}} {{/if}}[[signature]]`,
+		"signature": "-- {{Sender}}",
+	})
+
+	rows := []Answers{
+		{"name": "Alice", "vip": "no"},
+		{"name": "Carol", "vip": "yes"},
+		{"name": "Dave", "vip": "yes"},
+	}
+	merged, err := bm.Merge("invite", rows, "out/{{name | lower}}.txt", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []Merged{
+		{Row: rows[0], Value: "Hi Alice, -- Bob", Path: "out/alice.txt"},
+		{Row: rows[1], Value: "Hi Carol, late -- Bob", Path: "out/carol.txt"},
+		{Row: rows[2], Value: "Hi Dave, late -- Bob", Path: "out/dave.txt"},
+	}, merged)
+	assert.Equal(t, [][]string{{"Sender"}, {"Reason"}}, u.forms, "unmapped variables are asked once")
+
+	bp, _ := bm.Get("invite")
+	assert.Equal(t, 1, bp.Count)
+
+	// Rows take precedence over the answers given for all rows.
+	merged, err = bm.Merge("invite", rows[:1], "", ExpandOptions{Answers: Answers{"name": "Eve", "Sender": "Zoe"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Alice, -- Zoe", merged[0].Value)
+	assert.Empty(t, merged[0].Path)
+}
+
+func TestMerge_Errors(t *testing.T) {
+	bm := newTestEngine(t, &fakeUI{}, map[string]string{"bp": "{{name}} {{Count:int}}"})
+	rows := []Answers{{"name": "a", "Count": "1"}, {"name": "a", "Count": "2"}}
+
+	_, err := bm.Merge("bp", rows, "{{name}}.txt", ExpandOptions{})
+	assert.EqualError(t, err, `rows 1 and 2 have the same file name "a.txt"`)
+
+	_, err = bm.Merge("bp", rows, "../{{name}}", ExpandOptions{})
+	assert.ErrorContains(t, err, `row 1: invalid file path "../a"`)
+
+	rows[1]["Count"] = "many"
+	_, err = bm.Merge("bp", rows, "", ExpandOptions{})
+	assert.EqualError(t, err, `row 2: invalid answer for "Count": must be an integer`)
+
+	_, err = bm.Merge("bp", []Answers{{"name": "a"}}, "", ExpandOptions{NonInteractive: true})
+	assert.ErrorIs(t, err, ErrMissingAnswers)
+	assert.ErrorContains(t, err, "row 1: ")
+}
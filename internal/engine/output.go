@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// Replaced in tests.
+var (
+	clipboardWriteAll           = clipboard.WriteAll
+	stdout            io.Writer = os.Stdout
+)
+
+// OutputKind is the kind of destination of expanded boilerplates.
+type OutputKind string
+
+const (
+	OutputClipboard OutputKind = "clipboard"
+	OutputStdout    OutputKind = "stdout"
+	OutputFile      OutputKind = "file"   // overwrites the file at Path
+	OutputAppend    OutputKind = "append" // appends to the file at Path
+)
+
+// defaultOutput is the output used when none is configured.
+const defaultOutput = string(OutputClipboard)
+
+// Output is the destination of expanded boilerplates.
+type Output struct {
+	Kind OutputKind
+	// Path is the file written by file and append outputs.
+	Path string
+}
+
+// ParseOutput parses an output, as in "clipboard", "stdout", "file:<path>" or "append:<path>".
+// A leading "~/" in the path stands for the home directory.
+func ParseOutput(s string) (Output, error) {
+	kind, path, hasPath := strings.Cut(strings.TrimSpace(s), ":")
+	output := Output{Kind: OutputKind(kind)}
+
+	switch output.Kind {
+	case OutputClipboard, OutputStdout:
+		if hasPath {
+			return Output{}, fmt.Errorf("invalid output %q: unexpected path for %s", s, kind)
+		}
+
+	case OutputFile, OutputAppend:
+		if path == "" {
+			return Output{}, fmt.Errorf("invalid output %q: missing path, as in %s:<path>", s, kind)
+		}
+		var err error
+		if output.Path, err = expandHome(path); err != nil {
+			return Output{}, err
+		}
+
+	default:
+		return Output{}, fmt.Errorf("invalid output %q: expected clipboard, stdout, file:<path> or append:<path>", s)
+	}
+
+	return output, nil
+}
+
+func (o Output) String() string {
+	if o.Path == "" {
+		return string(o.Kind)
+	}
+	return string(o.Kind) + ":" + o.Path
+}
+
+// Action describes what writing to the output does, as in "copy to clipboard".
+func (o Output) Action() string {
+	switch o.Kind {
+	case OutputClipboard:
+		return "copy to clipboard"
+	case OutputStdout:
+		return "print"
+	case OutputFile:
+		return "write to " + o.Path
+	case OutputAppend:
+		return "append to " + o.Path
+	}
+	return o.String()
+}
+
+// Write writes an expanded boilerplate to the output.
+// Files are created if needed. Appended values are followed by a newline if they don't end
+// with one, so that each expansion starts on a new line.
+func (o Output) Write(value string) error {
+	switch o.Kind {
+	case OutputClipboard:
+		if err := clipboardWriteAll(value); err != nil {
+			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		}
+
+	case OutputStdout:
+		if _, err := io.WriteString(stdout, value); err != nil {
+			return fmt.Errorf("failed to write to stdout: %w", err)
+		}
+
+	case OutputFile:
+		if err := os.WriteFile(o.Path, []byte(value), 0o644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+
+	case OutputAppend:
+		f, err := os.OpenFile(o.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer f.Close()
+		if !strings.HasSuffix(value, "\n") {
+			value += "\n"
+		}
+		if _, err := f.WriteString(value); err != nil {
+			return fmt.Errorf("failed to append to output file: %w", err)
+		}
+		return f.Close()
+
+	default:
+		return fmt.Errorf("invalid output %q", o)
+	}
+
+	return nil
+}
+
+// expandHome replaces a leading "~/" in path by the home directory.
+func expandHome(path string) (string, error) {
+	rest, found := strings.CutPrefix(path, "~/")
+	if !found {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rest), nil
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutput(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		in      string
+		want    Output
+		wantErr string
+	}{
+		{in: "clipboard", want: Output{Kind: OutputClipboard}},
+		{in: "stdout", want: Output{Kind: OutputStdout}},
+		{in: "file:out.md", want: Output{Kind: OutputFile, Path: "out.md"}},
+		{in: "append:~/notes.md", want: Output{Kind: OutputAppend, Path: filepath.Join(home, "notes.md")}},
+		{in: "file:C:/out.md", want: Output{Kind: OutputFile, Path: "C:/out.md"}},
+		{in: "file:", wantErr: `invalid output "file:": missing path, as in file:<path>`},
+		{in: "stdout:out.md", wantErr: `invalid output "stdout:out.md": unexpected path for stdout`},
+		{in: "printer", wantErr: `invalid output "printer": expected clipboard, stdout, file:<path> or append:<path>`},
+	} {
+		output, err := ParseOutput(tc.in)
+		if tc.wantErr != "" {
+			assert.EqualError(t, err, tc.wantErr, tc.in)
+			continue
+		}
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, output, tc.in)
+	}
+}
+
+func TestOutput_Write(t *testing.T) {
+	writeAll, w := clipboardWriteAll, stdout
+	t.Cleanup(func() { clipboardWriteAll, stdout = writeAll, w })
+
+	var copied string
+	clipboardWriteAll = func(s string) error { copied = s; return nil }
+	var out strings.Builder
+	stdout = &out
+
+	require.NoError(t, Output{Kind: OutputClipboard}.Write("copied"))
+	assert.Equal(t, "copied", copied)
+
+	require.NoError(t, Output{Kind: OutputStdout}.Write("printed"))
+	assert.Equal(t, "printed", out.String())
+
+	path := filepath.Join(t.TempDir(), "out.md")
+	require.NoError(t, Output{Kind: OutputFile, Path: path}.Write("first"))
+	require.NoError(t, Output{Kind: OutputFile, Path: path}.Write("second"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+
+	require.NoError(t, Output{Kind: OutputAppend, Path: path}.Write("third"))
+	require.NoError(t, Output{Kind: OutputAppend, Path: path}.Write("fourth\n"))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "secondthird\nfourth\n", string(content))
+}
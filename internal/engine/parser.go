@@ -0,0 +1,787 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A boilerplate template is made of plain text interleaved with placeholders:
+//   - {{prompt}}: asks the user for free-form input.
+//   - {{prompt|a|b|c}}: asks the user to choose among a fixed set of answers.
+//   - {{name: prompt}} or {{name: prompt|a|b|c}}: same, storing the answer in
+//     the variable called name so that {{name}} reuses it.
+//   - {{prompt=default}} or {{prompt|a|*b|c}}: same, with a default answer.
+//   - {{prompt:int}}, {{prompt:date}}, {{prompt:email}} or {{prompt:/regexp/}}: asks until the answer is valid.
+//   - {{prompt|@boilerplate:name}}, {{prompt|@file:path}} or {{prompt|@cmd:command}}:
+//     choices read from the lines of a boilerplate, a file or the output of a command.
+//   - {{prompt[]|a|*b|*c}}: asks to choose any number of answers, b and c being preselected.
+//   - [[name]]: includes the boilerplate called name.
+//   - [[name var="value" other=value]]: same, pre-answering variables of the included boilerplate.
+//   - {{#if condition}}...{{else if condition}}...{{else}}...{{/if}}: conditional sections.
+//   - {{@name args}}: built-in variables resolved without prompting, e.g. {{@date}}.
+//   - {{$(command)}}: the output of a shell command, if allowed.
+//   - {{env:NAME}} or {{env:NAME ?? prompt}}: the value of an environment variable,
+//     asking the prompt when it is unset or empty.
+//   - {{placeholder | filter args}}: transforms the value of a placeholder, e.g. {{title | upper}}.
+//   - {{#raw}}...{{/raw}}: literal text, placeholders included.
+//   - \{{ and \[[: literal "{{" and "[[".
+//
+// Templates are lexed into a flat list of items, then parsed into a list of
+// nodes which is evaluated in a single pass. Answers given by the user are
+// written to the output as-is and are never parsed again.
+
+// itemType identifies the type of lexed items.
+type itemType int
+
+const (
+	itemText        itemType = iota // plain text
+	itemPlaceholder                 // {{...}}, val holds the inner content
+	itemInclude                     // [[...]], val holds the inner content
+)
+
+// item is a token returned by the lexer.
+type item struct {
+	typ itemType
+	pos int // byte offset of the item in the input
+	val string
+}
+
+const (
+	leftPlaceholder  = "{{"
+	rightPlaceholder = "}}"
+	leftInclude      = "[["
+	rightInclude     = "]]"
+	escape           = `\`
+	rawEnd           = "{{/raw}}"
+	envPrefix        = "env:"
+)
+
+var (
+	// includeNameRe matches valid names of included boilerplates.
+	includeNameRe = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+	// variableNameRe matches valid names of variables.
+	variableNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+	// envNameRe matches valid names of environment variables.
+	envNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// lex splits a template into text, placeholder and include items.
+// A "[[" that isn't followed by a valid boilerplate name and "]]" is kept as text,
+// and so is a "{{" that isn't closed by a "}}" without any "}" in between.
+// Delimiters preceded by a backslash and the content of raw blocks are kept as text.
+func lex(input string) ([]item, error) {
+	var (
+		items []item
+		text  strings.Builder
+		start int // start of the pending text item
+	)
+
+	flushText := func() {
+		if text.Len() > 0 {
+			items = append(items, item{typ: itemText, pos: start, val: text.String()})
+			text.Reset()
+		}
+	}
+
+	for pos := 0; pos < len(input); {
+		if text.Len() == 0 {
+			start = pos
+		}
+
+		switch {
+		case strings.HasPrefix(input[pos:], escape+leftPlaceholder) || strings.HasPrefix(input[pos:], escape+leftInclude):
+			text.WriteString(input[pos+len(escape) : pos+len(escape)+2])
+			pos += len(escape) + 2
+
+		case strings.HasPrefix(input[pos:], leftPlaceholder):
+			end := strings.Index(input[pos+len(leftPlaceholder):], rightPlaceholder)
+			if end < 0 {
+				text.WriteByte(input[pos])
+				pos++
+				continue
+			}
+			inner := input[pos+len(leftPlaceholder) : pos+len(leftPlaceholder)+end]
+			if strings.ContainsRune(inner, '}') {
+				// Not a placeholder, e.g. a C initializer.
+				text.WriteByte(input[pos])
+				pos++
+				continue
+			}
+			if strings.TrimSpace(inner) == "" {
+				return nil, newParseError(input, pos, "empty placeholder")
+			}
+			flushText()
+			items = append(items, item{typ: itemPlaceholder, pos: pos, val: inner})
+			pos += len(leftPlaceholder) + end + len(rightPlaceholder)
+
+			if strings.TrimSpace(inner) == "#raw" {
+				// Everything up to {{/raw}} is kept as is.
+				end := strings.Index(input[pos:], rawEnd)
+				if end < 0 {
+					return nil, newParseError(input, items[len(items)-1].pos, "unclosed {{#raw}} block")
+				}
+				if end > 0 {
+					items = append(items, item{typ: itemText, pos: pos, val: input[pos : pos+end]})
+				}
+				pos += end
+				items = append(items, item{typ: itemPlaceholder, pos: pos, val: rawEnd[len(leftPlaceholder) : len(rawEnd)-len(rightPlaceholder)]})
+				pos += len(rawEnd)
+			}
+
+		case strings.HasPrefix(input[pos:], leftInclude):
+			end := strings.Index(input[pos+len(leftInclude):], rightInclude)
+			if end < 0 {
+				text.WriteByte(input[pos])
+				pos++
+				continue
+			}
+			inner := input[pos+len(leftInclude) : pos+len(leftInclude)+end]
+			if _, _, ok := parseInclude(inner); !ok {
+				// Not an include, e.g. a Markdown or wiki link.
+				text.WriteByte(input[pos])
+				pos++
+				continue
+			}
+			flushText()
+			items = append(items, item{typ: itemInclude, pos: pos, val: inner})
+			pos += len(leftInclude) + end + len(rightInclude)
+
+		default:
+			text.WriteByte(input[pos])
+			pos++
+		}
+	}
+	flushText()
+
+	return items, nil
+}
+
+// node is an element of a parsed template.
+type node interface {
+	// Pos returns the byte offset of the node in the template.
+	Pos() int
+}
+
+// textNode holds plain text, copied verbatim to the output.
+type textNode struct {
+	pos  int
+	text string
+}
+
+// promptNode asks the user for a free-form answer.
+// The answer is stored in the variable called name, which defaults to the label.
+type promptNode struct {
+	pos          int
+	name         string
+	label        string
+	defaultValue string
+	typ          *promptType // nil for free-form answers
+	filters      []filterCall
+}
+
+// choiceNode asks the user to choose among a fixed set of answers.
+// The answer is stored in the variable called name, which defaults to the label.
+// A multi-select choice accepts several answers, stored as a list (see listSeparator),
+// its default value listing all the preselected choices.
+type choiceNode struct {
+	pos          int
+	name         string
+	label        string
+	choices      []string
+	defaultValue string
+	multi        bool
+	sources      []choiceSource
+	filters      []filterCall
+}
+
+// includeNode is replaced by the expansion of another boilerplate.
+// Its arguments answer variables of the included boilerplate, and of the
+// boilerplates it includes, without affecting the rest of the expansion.
+type includeNode struct {
+	pos  int
+	name string
+	args map[string]string
+}
+
+// commandNode is replaced by the output of a shell command.
+// It is only run if allowed by the configuration or if its boilerplate is trusted.
+type commandNode struct {
+	pos     int
+	command string
+	trusted bool
+	filters []filterCall
+}
+
+// envNode is replaced by the value of an environment variable.
+// When the variable is unset or empty, the fallback prompt is asked if there is one.
+type envNode struct {
+	pos      int
+	name     string
+	fallback *promptNode
+	filters  []filterCall
+}
+
+// builtinNode is replaced by the value of a built-in variable.
+type builtinNode struct {
+	pos     int
+	name    string
+	arg     string
+	filters []filterCall
+}
+
+// ifNode renders its then branch if its condition holds, its else branch otherwise.
+type ifNode struct {
+	pos  int
+	cond *condition
+	then []node
+	els  []node // an "else if" is an ifNode alone in the else branch
+}
+
+func (n *textNode) Pos() int    { return n.pos }
+func (n *promptNode) Pos() int  { return n.pos }
+func (n *choiceNode) Pos() int  { return n.pos }
+func (n *includeNode) Pos() int { return n.pos }
+func (n *commandNode) Pos() int { return n.pos }
+func (n *envNode) Pos() int     { return n.pos }
+func (n *builtinNode) Pos() int { return n.pos }
+func (n *ifNode) Pos() int      { return n.pos }
+
+// tag identifies the placeholders delimiting blocks.
+type tag int
+
+const (
+	tagNone   tag = iota // not a block delimiter
+	tagIf                // {{#if condition}}
+	tagElseIf            // {{else if condition}}
+	tagElse              // {{else}}
+	tagEndIf             // {{/if}}
+	tagRaw               // {{#raw}}
+	tagEndRaw            // {{/raw}}
+)
+
+// blockTag returns the block delimiter of a placeholder item, and its argument.
+func blockTag(it item) (tag, string) {
+	if it.typ != itemPlaceholder {
+		return tagNone, ""
+	}
+
+	val := strings.TrimSpace(it.val)
+	switch {
+	case val == "else":
+		return tagElse, ""
+	case val == "/if":
+		return tagEndIf, ""
+	case val == "#raw":
+		return tagRaw, ""
+	case val == "/raw":
+		return tagEndRaw, ""
+	case strings.HasPrefix(val, "#if ") || val == "#if":
+		return tagIf, strings.TrimSpace(val[len("#if"):])
+	case strings.HasPrefix(val, "else if ") || val == "else if":
+		return tagElseIf, strings.TrimSpace(val[len("else if"):])
+	}
+	return tagNone, ""
+}
+
+// parser builds the node tree from the lexed items.
+type parser struct {
+	input string
+	items []item
+	next  int // index of the next item to parse
+}
+
+// parse parses a template into a list of nodes.
+func parse(input string) ([]node, error) {
+	items, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	trimStandaloneTags(input, items)
+
+	p := &parser{input: input, items: items}
+	nodes, end, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if end != nil {
+		return nil, newParseError(input, end.pos, "unexpected {{%s}}", strings.TrimSpace(end.val))
+	}
+
+	return nodes, nil
+}
+
+// parseList parses items until the end of the input or a block delimiter
+// ({{else}}, {{else if}}, {{/if}} or a stray {{/raw}}), which is consumed and returned.
+func (p *parser) parseList() ([]node, *item, error) {
+	var nodes []node
+	for p.next < len(p.items) {
+		it := &p.items[p.next]
+		p.next++
+
+		switch it.typ {
+		case itemText:
+			if it.val != "" {
+				nodes = append(nodes, &textNode{pos: it.pos, text: it.val})
+			}
+		case itemInclude:
+			name, args, _ := parseInclude(it.val)
+			nodes = append(nodes, &includeNode{pos: it.pos, name: name, args: args})
+		case itemPlaceholder:
+			switch t, arg := blockTag(*it); t {
+			case tagNone:
+				n, err := p.parsePlaceholder(*it)
+				if err != nil {
+					return nil, nil, err
+				}
+				nodes = append(nodes, n)
+			case tagIf:
+				n, err := p.parseIf(it, arg)
+				if err != nil {
+					return nil, nil, err
+				}
+				nodes = append(nodes, n)
+			case tagRaw:
+				// The lexer guarantees the block is made of an optional text and its end.
+				if it := p.items[p.next]; it.typ == itemText {
+					if it.val != "" {
+						nodes = append(nodes, &textNode{pos: it.pos, text: it.val})
+					}
+					p.next++
+				}
+				p.next++
+			default:
+				return nodes, it, nil
+			}
+		}
+	}
+
+	return nodes, nil, nil
+}
+
+// parseIf parses the branches of a conditional block, up to its {{/if}}.
+// start is the {{#if}} or {{else if}} item opening the block.
+func (p *parser) parseIf(start *item, arg string) (*ifNode, error) {
+	cond, err := parseCondition(arg)
+	if err != nil {
+		return nil, newParseError(p.input, start.pos, "%v", err)
+	}
+
+	n := &ifNode{pos: start.pos, cond: cond}
+	var end *item
+	n.then, end, err = p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	if end != nil {
+		switch t, arg := blockTag(*end); t {
+		case tagElseIf:
+			elseIf, err := p.parseIf(end, arg)
+			if err != nil {
+				return nil, err
+			}
+			n.els = []node{elseIf}
+			return n, nil
+		case tagElse:
+			n.els, end, err = p.parseList()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if end == nil {
+		return nil, newParseError(p.input, start.pos, "unclosed {{#if}} block")
+	}
+	if t, _ := blockTag(*end); t != tagEndIf {
+		return nil, newParseError(p.input, end.pos, "unexpected {{%s}}", strings.TrimSpace(end.val))
+	}
+
+	return n, nil
+}
+
+// trimStandaloneTags removes the lines holding nothing but a block delimiter,
+// so that conditional sections don't leave blank lines behind.
+func trimStandaloneTags(input string, items []item) {
+	for i, it := range items {
+		if t, _ := blockTag(it); t == tagNone {
+			continue
+		}
+
+		end := it.pos + len(leftPlaceholder) + len(it.val) + len(rightPlaceholder)
+		lineStart := strings.LastIndexByte(input[:it.pos], '\n') + 1
+		lineEnd := len(input)
+		if idx := strings.IndexByte(input[end:], '\n'); idx >= 0 {
+			lineEnd = end + idx + 1
+		}
+		if strings.TrimSpace(input[lineStart:it.pos]) != "" || strings.TrimSpace(input[end:lineEnd]) != "" {
+			continue
+		}
+
+		// The surrounding text items end and start with the whitespace of the line.
+		if i > 0 && items[i-1].typ == itemText {
+			prev := &items[i-1]
+			prev.val = prev.val[:max(len(prev.val)-(it.pos-lineStart), 0)]
+		}
+		if i+1 < len(items) && items[i+1].typ == itemText {
+			next := &items[i+1]
+			removed := min(lineEnd-end, len(next.val))
+			next.val = next.val[removed:]
+			next.pos += removed
+		}
+	}
+}
+
+// parseInclude parses the content of a [[...]] item: a boilerplate name
+// optionally followed by arguments, as in `signature name="Ops team" tone=formal`.
+// Argument values are either double-quoted or end at the next space.
+// ok is false if the content is not a valid include.
+func parseInclude(s string) (name string, args map[string]string, ok bool) {
+	name, rest, _ := strings.Cut(s, " ")
+	if !includeNameRe.MatchString(name) {
+		return "", nil, false
+	}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, found := strings.Cut(rest, "=")
+		if !found || !variableNameRe.MatchString(key) {
+			return "", nil, false
+		}
+
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return "", nil, false
+			}
+			rest = value[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			value, rest, _ = strings.Cut(value, " ")
+		}
+
+		if args == nil {
+			args = make(map[string]string)
+		}
+		args[key] = value
+	}
+
+	return name, args, true
+}
+
+// parsePlaceholder turns the content of a {{...}} item into a built-in, a prompt or a choice node.
+// "{{prompt}}" asks an open question while "{{prompt|a|b|c}}" offers a fixed set of answers.
+// Both can be prefixed by a variable name, as in "{{name: prompt}}".
+// The default answer follows an equal sign ("{{prompt=default}}"), or is the
+// choice marked with a star ("{{prompt|a|*b|c}}").
+// Filters are chained at the end, each one following a " |", as in "{{prompt | trim | upper}}".
+func (p *parser) parsePlaceholder(it item) (node, error) {
+	if strings.HasPrefix(strings.TrimSpace(it.val), "$(") {
+		return p.parseCommand(it)
+	}
+
+	content, pattern, err := cutPattern(it.val)
+	if err != nil {
+		return nil, newParseError(p.input, it.pos, "%v", err)
+	}
+	segments, calls := cutFilters(splitSegments(content))
+
+	if head := strings.TrimSpace(segments[0]); strings.HasPrefix(head, "@") {
+		name, arg, _ := strings.Cut(head[1:], " ")
+		if _, found := builtins[name]; !found {
+			return nil, newParseError(p.input, it.pos, "unknown built-in variable @%s", name)
+		}
+		if len(segments) > 1 {
+			return nil, newParseError(p.input, it.pos, "unexpected choices for built-in variable @%s", name)
+		}
+		if pattern != nil {
+			return nil, newParseError(p.input, it.pos, "unexpected type for built-in variable @%s", name)
+		}
+		return &builtinNode{pos: it.pos, name: name, arg: strings.TrimSpace(arg), filters: calls}, nil
+	}
+
+	if rest, found := strings.CutPrefix(strings.TrimSpace(segments[0]), envPrefix); found && rest != "" && rest[0] != ' ' {
+		if len(segments) > 1 {
+			return nil, newParseError(p.input, it.pos, "unexpected choices for environment variable")
+		}
+		return p.parseEnv(it, rest, pattern, calls)
+	}
+
+	head, defaultValue, _ := strings.Cut(segments[0], "=")
+	head, multi := cutMultiMarker(head)
+	typ := pattern
+	if typ == nil {
+		head, typ = cutType(head)
+	}
+	name, label := parseVariable(head)
+	if label == "" {
+		return nil, newParseError(p.input, it.pos, "missing prompt")
+	}
+	defaultValue = strings.TrimSpace(defaultValue)
+
+	if len(segments) == 1 {
+		if multi {
+			return nil, newParseError(p.input, it.pos, "missing choices for multi-select %q", label)
+		}
+		return &promptNode{pos: it.pos, name: name, label: label, defaultValue: defaultValue, typ: typ, filters: calls}, nil
+	}
+	if typ != nil {
+		return nil, newParseError(p.input, it.pos, "unexpected type for choices %q", label)
+	}
+
+	var defaults []string
+	if defaultValue != "" {
+		defaults = append(defaults, defaultValue)
+	}
+	var sources []choiceSource
+	choices := make([]string, 0, len(segments)-1)
+	for _, choice := range segments[1:] {
+		choice = strings.TrimSpace(choice)
+		if src, ok := parseChoiceSource(choice); ok {
+			sources = append(sources, src)
+			continue
+		}
+		marked, isDefault := strings.CutPrefix(choice, "*")
+		if isDefault {
+			choice = strings.TrimSpace(marked)
+		}
+		if choice == "" {
+			return nil, newParseError(p.input, it.pos, "empty choice for %q", label)
+		}
+		if isDefault && (multi || len(defaults) == 0) {
+			defaults = append(defaults, choice)
+		}
+		choices = append(choices, choice)
+	}
+	return &choiceNode{
+		pos:          it.pos,
+		name:         name,
+		label:        label,
+		choices:      choices,
+		defaultValue: strings.Join(defaults, listSeparator),
+		multi:        multi,
+		sources:      sources,
+		filters:      calls,
+	}, nil
+}
+
+// parseEnv parses an environment variable placeholder such as "JIRA_PROJECT ?? Jira project=ABC",
+// the part following "??" being a prompt asked when the variable is unset or empty.
+// pattern is the regular expression type of the prompt, already cut from s.
+func (p *parser) parseEnv(it item, s string, pattern *promptType, calls []filterCall) (node, error) {
+	name, fallback, found := strings.Cut(s, "??")
+	name = strings.TrimSpace(name)
+	if !envNameRe.MatchString(name) {
+		return nil, newParseError(p.input, it.pos, "invalid environment variable name %q", name)
+	}
+
+	if !found && pattern != nil {
+		return nil, newParseError(p.input, it.pos, "unexpected type for environment variable %s", name)
+	}
+
+	n := &envNode{pos: it.pos, name: name, filters: calls}
+	if found {
+		head, defaultValue, _ := strings.Cut(fallback, "=")
+		typ := pattern
+		if typ == nil {
+			head, typ = cutType(head)
+		}
+		if head = strings.TrimSpace(head); head == "" {
+			return nil, newParseError(p.input, it.pos, "missing prompt for environment variable %s", name)
+		}
+		varName, label := parseVariable(head)
+		n.fallback = &promptNode{pos: it.pos, name: varName, label: label, defaultValue: strings.TrimSpace(defaultValue), typ: typ}
+	}
+	return n, nil
+}
+
+// parseCommand parses a command placeholder, as in "$(git branch --show-current) | upper".
+// The command ends at the parenthesis matching the opening one, outside of quotes.
+func (p *parser) parseCommand(it item) (node, error) {
+	s := strings.TrimSpace(it.val)
+	depth := 0
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			command := strings.TrimSpace(s[2:i])
+			if command == "" {
+				return nil, newParseError(p.input, it.pos, "empty command")
+			}
+			segments := splitSegments(s[i+1:])
+			if strings.TrimSpace(segments[0]) != "" {
+				return nil, newParseError(p.input, it.pos, "unexpected %q after command", strings.TrimSpace(segments[0]))
+			}
+			var calls []filterCall
+			for _, segment := range segments[1:] {
+				call, err := parseFilterCall(segment)
+				if err != nil {
+					return nil, newParseError(p.input, it.pos, "%v", err)
+				}
+				calls = append(calls, call)
+			}
+			return &commandNode{pos: it.pos, command: command, filters: calls}, nil
+		}
+	}
+	return nil, newParseError(p.input, it.pos, "unclosed command")
+}
+
+// cutMultiMarker removes the "[]" marking a multi-select from the variable
+// name or label of a placeholder head, as in "services[]: Services" or "Services[]".
+func cutMultiMarker(head string) (string, bool) {
+	head = strings.TrimSpace(head)
+	if before, found := strings.CutSuffix(head, "[]"); found {
+		return before, true
+	}
+	if name, label, found := strings.Cut(head, "[]:"); found && variableNameRe.MatchString(strings.TrimSpace(name)) {
+		return name + ":" + label, true
+	}
+	return head, false
+}
+
+// splitSegments splits the content of a placeholder on pipes, except those
+// inside double-quoted strings. If a quote is never closed, quotes are ignored.
+func splitSegments(s string) []string {
+	var (
+		segments []string
+		start    int
+		quoted   bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '|' && !quoted:
+			segments = append(segments, s[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return strings.Split(s, "|")
+	}
+	return append(segments, s[start:])
+}
+
+// cutFilters removes the filters ending the segments of a placeholder.
+// The filters are the longest run of trailing segments which are all valid filter calls,
+// so that "{{Format | json | csv}}" keeps offering the choices "json" and "csv".
+func cutFilters(segments []string) ([]string, []filterCall) {
+next:
+	for i := 1; i < len(segments); i++ {
+		calls := make([]filterCall, 0, len(segments)-i)
+		for _, segment := range segments[i:] {
+			if !isFilterSegment(segment) {
+				continue next
+			}
+			call, err := parseFilterCall(segment)
+			if err != nil {
+				continue next
+			}
+			calls = append(calls, call)
+		}
+		return segments[:i], calls
+	}
+	return segments, nil
+}
+
+// isFilterSegment reports whether a placeholder segment is a filter:
+// it must follow the pipe after a space and start with the name of a registered filter.
+func isFilterSegment(segment string) bool {
+	if segment == "" || (segment[0] != ' ' && segment[0] != '\t') {
+		return false
+	}
+	name, _, _ := strings.Cut(strings.TrimSpace(segment), " ")
+	_, found := filters[name]
+	return found
+}
+
+// parseFilterCall parses a filter and its arguments, as in `default "x"`.
+// Arguments are separated by spaces and can be double-quoted.
+func parseFilterCall(segment string) (filterCall, error) {
+	fields, err := splitArgs(segment)
+	if err != nil {
+		return filterCall{}, err
+	}
+	if len(fields) == 0 {
+		return filterCall{}, errors.New("missing filter")
+	}
+	if _, found := filters[fields[0]]; !found {
+		return filterCall{}, fmt.Errorf("unknown filter %q", fields[0])
+	}
+	return filterCall{name: fields[0], args: fields[1:]}, nil
+}
+
+// splitArgs splits a string on spaces, unquoting double-quoted arguments.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string in %q", s)
+			}
+			arg, _ := strconv.Unquote(quoted)
+			args = append(args, arg)
+			s = s[len(quoted):]
+			continue
+		}
+		arg, rest, _ := strings.Cut(s, " ")
+		args = append(args, arg)
+		s = rest
+	}
+	return args, nil
+}
+
+// parseVariable splits "name: label" into its variable name and label.
+// When there is no valid name before the colon, the whole string is the label
+// and is used as the variable name as well.
+func parseVariable(s string) (name, label string) {
+	s = strings.TrimSpace(s)
+	if idx := strings.IndexByte(s, ':'); idx >= 0 {
+		name, label = strings.TrimSpace(s[:idx]), strings.TrimSpace(s[idx+1:])
+		if variableNameRe.MatchString(name) && label != "" {
+			return name, label
+		}
+	}
+	return s, s
+}
+
+// ParseError reports a syntax error in a template.
+type ParseError struct {
+	Line   int // 1-based line of the error
+	Column int // 1-based column of the error, in bytes
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// newParseError builds a ParseError located at the given byte offset of input.
+func newParseError(input string, pos int, format string, args ...any) *ParseError {
+	line := 1 + strings.Count(input[:pos], "\n")
+	column := pos + 1
+	if idx := strings.LastIndexByte(input[:pos], '\n'); idx >= 0 {
+		column = pos - idx
+	}
+	return &ParseError{Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	nodes, err := parse("Hello {{Your name}}, color: {{Color|red|green}}.\n[[signature]]")
+	require.NoError(t, err)
+
+	assert.Equal(t, []node{
+		&textNode{pos: 0, text: "Hello "},
+		&promptNode{pos: 6, name: "Your name", label: "Your name"},
+		&textNode{pos: 19, text: ", color: "},
+		&choiceNode{pos: 28, name: "Color", label: "Color", choices: []string{"red", "green"}},
+		&textNode{pos: 47, text: ".\n"},
+		&includeNode{pos: 49, name: "signature"},
+	}, nodes)
+}
+
+func TestParse_Defaults(t *testing.T) {
+	nodes, err := parse("{{prio: Ticket priority = P2}}{{Env|dev|*prod|*staging}}{{Env=dev|dev|*prod}}")
+	require.NoError(t, err)
+
+	assert.Equal(t, []node{
+		&promptNode{pos: 0, name: "prio", label: "Ticket priority", defaultValue: "P2"},
+		&choiceNode{pos: 30, name: "Env", label: "Env", choices: []string{"dev", "prod", "staging"}, defaultValue: "prod"},
+		&choiceNode{pos: 56, name: "Env", label: "Env", choices: []string{"dev", "prod"}, defaultValue: "dev"},
+	}, nodes)
+}
+
+func TestParse_If(t *testing.T) {
+	nodes, err := parse("A\n{{#if x == \"1\"}}\nB\n{{else if !y}}\nC\n{{else}}\nD\n{{/if}}\nE")
+	require.NoError(t, err)
+
+	assert.Equal(t, []node{
+		&textNode{pos: 0, text: "A\n"},
+		&ifNode{
+			pos:  2,
+			cond: &condition{left: operand{variable: "x"}, op: "==", right: operand{literal: "1"}},
+			then: []node{&textNode{pos: 19, text: "B\n"}},
+			els: []node{&ifNode{
+				pos:  21,
+				cond: &condition{negate: true, left: operand{variable: "y"}},
+				then: []node{&textNode{pos: 36, text: "C\n"}},
+				els:  []node{&textNode{pos: 47, text: "D\n"}},
+			}},
+		},
+		&textNode{pos: 57, text: "E"},
+	}, nodes)
+}
+
+func TestParse_IfErrors(t *testing.T) {
+	for input, msg := range map[string]string{
+		"{{#if a}}":                 "1:1: unclosed {{#if}} block",
+		"{{#if a}}{{else}}{{else}}": "1:18: unexpected {{else}}",
+		"text{{/if}}":               "1:5: unexpected {{/if}}",
+		"{{else}}":                  "1:1: unexpected {{else}}",
+		"{{#if}}{{/if}}":            "1:1: missing condition",
+		"{{#if a ==}}{{/if}}":       `1:1: invalid condition "a =="`,
+		"{{#if a b}}{{/if}}":        `1:1: invalid condition "a b"`,
+		"{{#if \"a}}{{/if}}":        `1:1: invalid string in condition "\"a"`,
+	} {
+		_, err := parse(input)
+		assert.EqualError(t, err, msg, input)
+	}
+}
+
+func TestParse_Escapes(t *testing.T) {
+	nodes, err := parse("Literal \\{{name}} and \\[[link]]:\n{{#raw}}\n{{ .Values.tag }} [[include]]\n{{/raw}}\nend")
+	require.NoError(t, err)
+
+	assert.Equal(t, []node{
+		&textNode{pos: 0, text: "Literal {{name}} and [[link]]:\n"},
+		&textNode{pos: 42, text: "{{ .Values.tag }} [[include]]\n"},
+		&textNode{pos: 81, text: "end"},
+	}, nodes)
+
+	_, err = parse("{{#raw}}{{x}}")
+	assert.EqualError(t, err, "1:1: unclosed {{#raw}} block")
+
+	_, err = parse("{{/raw}}")
+	assert.EqualError(t, err, "1:1: unexpected {{/raw}}")
+}
+
+func TestParseVariable(t *testing.T) {
+	for _, tc := range []struct {
+		input, name, label string
+	}{
+		{"name", "name", "name"},
+		{"name: Your name", "name", "Your name"},
+		{" name :Your name ", "name", "Your name"},
+		{"Your name", "Your name", "Your name"},
+		{"Enter your name:", "Enter your name:", "Enter your name:"},
+		{"Name:", "Name:", "Name:"},
+		{"Time: 10:30", "Time", "10:30"},
+	} {
+		name, label := parseVariable(tc.input)
+		assert.Equal(t, tc.name, name, tc.input)
+		assert.Equal(t, tc.label, label, tc.input)
+	}
+}
+
+func TestParse_NotAPlaceholder(t *testing.T) {
+	for _, input := range []string{
+		"Use {{ to open",
+		"Hello {{name",
+		"int a[2][2] = {{1,2},{3,4}};",
+		"{{a}b}}",
+	} {
+		nodes, err := parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, []node{&textNode{pos: 0, text: input}}, nodes, input)
+	}
+}
+
+func TestParse_NotAnInclude(t *testing.T) {
+	for _, input := range []string{
+		"[[not a name]]",
+		"[[unterminated",
+		"[[[link]",
+		"[[name arg]]",
+		"[[name arg=\"unterminated]]",
+	} {
+		nodes, err := parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, []node{&textNode{pos: 0, text: input}}, nodes, input)
+	}
+}
+
+func TestParse_MultiSelect(t *testing.T) {
+	nodes, err := parse("{{services[]: Services|*api|web|*db}} {{Teams[]=ops|ops|dev}}")
+	require.NoError(t, err)
+	assert.Equal(t, []node{
+		&choiceNode{pos: 0, name: "services", label: "Services", choices: []string{"api", "web", "db"}, defaultValue: "api\ndb", multi: true},
+		&textNode{pos: 37, text: " "},
+		&choiceNode{pos: 38, name: "Teams", label: "Teams", choices: []string{"ops", "dev"}, defaultValue: "ops", multi: true},
+	}, nodes)
+
+	_, err = parse("{{Services[]}}")
+	assert.EqualError(t, err, `1:1: missing choices for multi-select "Services"`)
+}
+
+func TestParse_ChoiceSources(t *testing.T) {
+	nodes, err := parse(`{{Assignee|*me|@boilerplate:team_members|@cmd:"git branch | sort"|@home|@nope:x}}`)
+	require.NoError(t, err)
+	assert.Equal(t, []node{
+		&choiceNode{pos: 0, name: "Assignee", label: "Assignee", choices: []string{"me", "@home", "@nope:x"}, defaultValue: "me", sources: []choiceSource{
+			{kind: "boilerplate", arg: "team_members"},
+			{kind: "cmd", arg: "git branch | sort"},
+		}},
+	}, nodes)
+}
+
+func TestParse_Commands(t *testing.T) {
+	nodes, err := parse(`{{$(git log --format="%h)" -1) | upper}}`)
+	require.NoError(t, err)
+	assert.Equal(t, []node{
+		&commandNode{pos: 0, command: `git log --format="%h)" -1`, filters: []filterCall{{name: "upper", args: []string{}}}},
+	}, nodes)
+
+	for input, msg := range map[string]string{
+		"{{$(git log}}": "1:1: unclosed command",
+		"{{$( )}}":      "1:1: empty command",
+		"{{$(ls) x}}":   `1:1: unexpected "x" after command`,
+	} {
+		_, err := parse(input)
+		assert.EqualError(t, err, msg, input)
+	}
+}
+
+func TestParse_Env(t *testing.T) {
+	nodes, err := parse("{{env:JIRA_PROJECT}}{{env:TEAM ?? team: Your team=ops | upper}}{{env: Environment}}")
+	require.NoError(t, err)
+	assert.Equal(t, []node{
+		&envNode{pos: 0, name: "JIRA_PROJECT"},
+		&envNode{pos: 20, name: "TEAM", fallback: &promptNode{pos: 20, name: "team", label: "Your team", defaultValue: "ops"}, filters: []filterCall{
+			{name: "upper", args: []string{}},
+		}},
+		&promptNode{pos: 63, name: "env", label: "Environment"},
+	}, nodes)
+
+	for input, msg := range map[string]string{
+		"{{env:NOT-VALID}}": `1:1: invalid environment variable name "NOT-VALID"`,
+		"{{env:HOME ?? }}":  "1:1: missing prompt for environment variable HOME",
+		"{{env:HOME|a|b}}":  "1:1: unexpected choices for environment variable",
+	} {
+		_, err := parse(input)
+		assert.EqualError(t, err, msg, input)
+	}
+}
+
+func TestParse_Types(t *testing.T) {
+	nodes, err := parse("{{count: Count:int=1}}{{env:DUE ?? Due:date}}")
+	require.NoError(t, err)
+	assert.Equal(t, []node{
+		&promptNode{pos: 0, name: "count", label: "Count", defaultValue: "1", typ: &promptType{name: "int"}},
+		&envNode{pos: 22, name: "DUE", fallback: &promptNode{pos: 22, name: "Due", label: "Due", typ: &promptType{name: "date"}}},
+	}, nodes)
+
+	// Regular expressions may contain pipes and equal signs.
+	nodes, err = parse("{{Env:/^(a|b)$/}}{{pair: Pair:/^a=b$/=a=b | upper}}{{env:KEY ?? Key:/^(x|y)$/}}")
+	require.NoError(t, err)
+	assert.Equal(t, []node{
+		&promptNode{pos: 0, name: "Env", label: "Env", typ: &promptType{pattern: regexp.MustCompile("^(a|b)$")}},
+		&promptNode{pos: 17, name: "pair", label: "Pair", defaultValue: "a=b", typ: &promptType{pattern: regexp.MustCompile("^a=b$")}, filters: []filterCall{
+			{name: "upper", args: []string{}},
+		}},
+		&envNode{pos: 51, name: "KEY", fallback: &promptNode{pos: 51, name: "Key", label: "Key", typ: &promptType{pattern: regexp.MustCompile("^(x|y)$")}}},
+	}, nodes)
+
+	for input, msg := range map[string]string{
+		"{{Count:int|1|2}}":   `1:1: unexpected type for choices "Count"`,
+		"{{Env:/^(a|b)$/|a}}": `1:1: unexpected type for choices "Env"`,
+		"{{@date:/^a|b$/}}":   "1:1: unexpected type for built-in variable @date",
+		"{{env:KEY:/^a|b$/}}": "1:1: unexpected type for environment variable KEY",
+	} {
+		_, err := parse(input)
+		assert.EqualError(t, err, msg, input)
+	}
+
+	_, err = parse("{{Ticket:/[/}}")
+	assert.ErrorContains(t, err, "1:1: invalid regular expression")
+}
+
+func TestParseInclude(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		name  string
+		args  map[string]string
+	}{
+		{"signature", "signature", nil},
+		{`signature name="Ops team" tone=formal`, "signature", map[string]string{"name": "Ops team", "tone": "formal"}},
+		{`footer  year="2024"  `, "footer", map[string]string{"year": "2024"}},
+		{`quote text="say \"hi\""`, "quote", map[string]string{"text": `say "hi"`}},
+	} {
+		name, args, ok := parseInclude(tc.input)
+		require.True(t, ok, tc.input)
+		assert.Equal(t, tc.name, name, tc.input)
+		assert.Equal(t, tc.args, args, tc.input)
+	}
+
+	nodes, err := parse(`[[signature tone=formal]]`)
+	require.NoError(t, err)
+	assert.Equal(t, []node{&includeNode{pos: 0, name: "signature", args: map[string]string{"tone": "formal"}}}, nodes)
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := parse("line one\nHello {{#if a}}")
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.Line)
+	assert.Equal(t, 7, parseErr.Column)
+	assert.EqualError(t, err, "2:7: unclosed {{#if}} block")
+
+	_, err = parse("{{ }}")
+	assert.EqualError(t, err, "1:1: empty placeholder")
+
+	for input, msg := range map[string]string{
+		"{{ | upper}}": "1:1: missing prompt",
+		"{{|}}":        "1:1: missing prompt",
+		"{{=x}}":       "1:1: missing prompt",
+		"{{[]|a}}":     "1:1: missing prompt",
+		"{{x||}}":      `1:1: empty choice for "x"`,
+		"{{x|*}}":      `1:1: empty choice for "x"`,
+		"{{x|a| * }}":  `1:1: empty choice for "x"`,
+		"a\n  {{=x}}":  "2:3: missing prompt",
+	} {
+		_, err := parse(input)
+		assert.EqualError(t, err, msg, input)
+	}
+}
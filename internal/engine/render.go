@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Markers delimit the regions of a document rendered by RenderDocument, in HTML comments
+// or in line comments starting with "#" or "//":
+//
+//	<!-- ezbp:begin [[oncall]] -->
+//	...
+//	<!-- ezbp:end -->
+var (
+	beginMarkerRe = regexp.MustCompile(`^\s*(?:<!--|#|//)\s*ezbp:begin\s+(.*?)\s*(?:-->)?\s*$`)
+	endMarkerRe   = regexp.MustCompile(`^\s*(?:<!--|#|//)\s*ezbp:end\s*(?:-->)?\s*$`)
+)
+
+// region is a part of a document delimited by markers.
+type region struct {
+	template string
+	// begin and end are the indexes of the lines of the markers.
+	begin, end int
+}
+
+// findRegions lists the regions of a document split in lines, which must not be nested.
+func findRegions(lines []string) ([]region, error) {
+	var (
+		regions []region
+		current *region
+	)
+	for i, line := range lines {
+		if m := beginMarkerRe.FindStringSubmatch(line); m != nil {
+			if current != nil {
+				return nil, fmt.Errorf("line %d: ezbp:begin inside the region started line %d", i+1, current.begin+1)
+			}
+			current = &region{template: m[1], begin: i}
+		} else if endMarkerRe.MatchString(line) {
+			if current == nil {
+				return nil, fmt.Errorf("line %d: ezbp:end without ezbp:begin", i+1)
+			}
+			current.end = i
+			regions = append(regions, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("line %d: ezbp:begin without ezbp:end", current.begin+1)
+	}
+	return regions, nil
+}
+
+// HasRegions reports whether a document has regions delimited by markers, see RenderDocument.
+func HasRegions(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		if beginMarkerRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderDocument expands the boilerplate references and placeholders of a document.
+// If the document has regions delimited by markers, as in
+//
+//	<!-- ezbp:begin [[oncall service="api"]] -->
+//	<!-- ezbp:end -->
+//
+// only the content of the regions is replaced, by the expansion of the template of their
+// begin marker, and the markers are kept so that the document can be rendered again
+// when the boilerplates change. The rest of the document is left as-is.
+// Otherwise, the whole document is expanded as a template, see ExpandTemplate.
+// The variables of all the regions are asked once. No usage count is incremented.
+func (bm *Engine) RenderDocument(doc string, opts ExpandOptions) (string, error) {
+	lines := strings.Split(doc, "\n")
+	regions, err := findRegions(lines)
+	if err != nil {
+		return "", err
+	}
+	if len(regions) == 0 {
+		return bm.ExpandTemplate(doc, opts)
+	}
+
+	parts := make([][]node, 0, len(regions))
+	for _, r := range regions {
+		nodes, err := parse(r.template)
+		if err != nil {
+			return "", fmt.Errorf("line %d: unable to parse template: %w", r.begin+1, err)
+		}
+		parts = append(parts, nodes)
+	}
+
+	values, err := bm.newExpansion(templateName).expandParts(parts, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var out []string
+	previous := 0
+	for i, r := range regions {
+		out = append(out, lines[previous:r.begin+1]...)
+		if value := strings.TrimSuffix(values[i], "\n"); value != "" {
+			out = append(out, value)
+		}
+		previous = r.end
+	}
+	out = append(out, lines[previous:]...)
+
+	return strings.Join(out, "\n"), nil
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDocument(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Service": "api"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"oncall":   "Page {{Service}} on-call.\n",
+		"contacts": "- {{Service}} team",
+	})
+
+	doc := `# Runbook
+
+Code samples keep their {{braces}}.
+
+<!-- ezbp:begin [[oncall]] -->
+Outdated content.
+<!-- ezbp:end -->
+
+# ezbp:begin [[contacts]]
+# ezbp:end
+`
+	want := `# Runbook
+
+Code samples keep their {{braces}}.
+
+<!-- ezbp:begin [[oncall]] -->
+Page api on-call.
+<!-- ezbp:end -->
+
+# ezbp:begin [[contacts]]
+- api team
+# ezbp:end
+`
+
+	value, err := bm.RenderDocument(doc, ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, want, value)
+	assert.Equal(t, [][]string{{"Service"}}, u.forms, "variables are asked once for all regions")
+
+	// Rendering again gives the same document.
+	value, err = bm.RenderDocument(value, ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, want, value)
+
+	// Documents without regions are expanded as a whole.
+	value, err = bm.RenderDocument("Hi {{Service}}", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi api", value)
+}
+
+func TestRenderDocument_Errors(t *testing.T) {
+	bm := newTestEngine(t, &fakeUI{}, nil)
+
+	for doc, wantErr := range map[string]string{
+		"a\n<!-- ezbp:begin [[x]] -->\nb":                                "line 2: ezbp:begin without ezbp:end",
+		"<!-- ezbp:end -->":                                              "line 1: ezbp:end without ezbp:begin",
+		"# ezbp:begin [[x]]\n# ezbp:begin [[y]]\n# ezbp:end\n# ezbp:end": "line 2: ezbp:begin inside the region started line 1",
+		"# ezbp:begin {{#if x}}\n# ezbp:end":                             "line 1: unable to parse template",
+		"# ezbp:begin [[unknown]]\n# ezbp:end":                           `unknown referenced boilerplate "unknown"`,
+	} {
+		_, err := bm.RenderDocument(doc, ExpandOptions{})
+		assert.ErrorContains(t, err, wantErr, doc)
+	}
+}
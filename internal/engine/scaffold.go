@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrFileExists is returned by WriteFiles when files already exist and can't be overwritten.
+var ErrFileExists = errors.New("files already exist")
+
+// fileHeaderRe matches the lines starting the files of a scaffold, as in
+//
+//	==> cmd/{{name}}/main.go <==
+var fileHeaderRe = regexp.MustCompile(`^==>\s*(.*?)\s*<==\s*$`)
+
+// File is a file of an expanded scaffold.
+type File struct {
+	// Path is relative to the directory the scaffold is written to, using slashes.
+	// Paths ending with a slash are empty directories.
+	Path    string
+	Content string
+	// Private files are written with mode 0600, as the expansion answered a secret prompt.
+	Private bool
+}
+
+// scaffoldEntry is a file of a scaffold, before expansion.
+type scaffoldEntry struct {
+	path, content string
+	// line is the line number of its header, and column the column of its path in it.
+	line, column int
+}
+
+// splitScaffold splits the value of a scaffold in files, each one starting with a header line.
+// The content of a file is made of the lines following its header, up to the next header.
+func splitScaffold(value string) ([]scaffoldEntry, error) {
+	var entries []scaffoldEntry
+	for i, line := range strings.SplitAfter(value, "\n") {
+		if m := fileHeaderRe.FindStringSubmatchIndex(strings.TrimSuffix(line, "\n")); m != nil {
+			if m[2] == m[3] {
+				return nil, fmt.Errorf("line %d: empty file path", i+1)
+			}
+			entries = append(entries, scaffoldEntry{path: line[m[2]:m[3]], line: i + 1, column: m[2] + 1})
+			continue
+		}
+		if len(entries) == 0 {
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("line %d: content before the first ==> path <== header", i+1)
+			}
+			continue
+		}
+		entries[len(entries)-1].content += line
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no ==> path <== header")
+	}
+	return entries, nil
+}
+
+// parseScaffold parses the paths and contents of the scaffold called name, in this order.
+// The paths are parsed with their header, so that previews show them.
+func (ex *expansion) parseScaffold(name string) ([][]node, error) {
+	bp := ex.bm.boilerplates[name]
+	entries, err := splitScaffold(bp.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scaffold %q: %w", name, err)
+	}
+
+	parts := make([][]node, 0, 2*len(entries))
+	for _, entry := range entries {
+		for i, template := range []string{headerPrefix + entry.path + " <==", entry.content} {
+			nodes := []node{&textNode{text: template}}
+			if !bp.Raw {
+				if nodes, err = parse(template); err != nil {
+					return nil, fmt.Errorf("unable to parse scaffold %q: %w", name, entry.locate(err, i == 0))
+				}
+				if bp.Trusted {
+					trust(nodes)
+				}
+			}
+			parts = append(parts, nodes)
+		}
+	}
+	return parts, nil
+}
+
+// headerPrefix starts the headers as parsed by parseScaffold.
+const headerPrefix = "==> "
+
+// locate moves the position of a parse error of the header or the content of the entry,
+// parsed on their own, to the value of the scaffold.
+func (entry scaffoldEntry) locate(err error, header bool) error {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		return err
+	}
+	located := *perr
+	if header {
+		located.Line = entry.line
+		located.Column += entry.column - 1 - len(headerPrefix)
+	} else {
+		located.Line += entry.line
+	}
+	return &located
+}
+
+// Scaffold expands a scaffold, a boilerplate describing a tree of files, as in
+//
+//	==> {{Name}}/go.mod <==
+//	module example.com/{{Name}}
+//	==> {{Name}}/internal/ <==
+//
+// Each file starts with a header line giving its path, followed by its content.
+// Paths ending with a slash are empty directories. Paths and contents are expanded
+// as a whole, as ExpandWith does: each variable is asked once and shared by all
+// the files. Files whose path expands to an empty string are skipped, so that they
+// can be made conditional. Blocks such as {{#if}} can't span several files. Paths
+// must be relative and stay inside the destination. Files are private when a secret
+// prompt was answered, since their content may hold the secret.
+// The usage count of the scaffold is incremented, unless opts.DryRun is set.
+func (bm *Engine) Scaffold(name string, opts ExpandOptions) ([]File, error) {
+	bp, found := bm.boilerplates[name]
+	if !found {
+		return nil, fmt.Errorf("unknown boilerplate %q", name)
+	}
+	if !bp.Scaffold {
+		return nil, fmt.Errorf("boilerplate %q is not a scaffold", name)
+	}
+
+	ex := bm.newExpansion(name)
+	parts, err := ex.parseScaffold(name)
+	if err != nil {
+		return nil, err
+	}
+	values, err := ex.expandParts(parts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	private := len(ex.secrets()) > 0
+	var files []File
+	for i := 0; i < len(values); i += 2 {
+		path := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(values[i], "==>"), "<=="))
+		if path == "" {
+			continue
+		}
+		if err := checkPath(path); err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(files, func(f File) bool { return f.Path == path }) {
+			return nil, fmt.Errorf("duplicate file path %q", path)
+		}
+		file := File{Path: path, Content: values[i+1], Private: private && !isDir(path)}
+		if isDir(path) && strings.TrimSpace(file.Content) != "" {
+			return nil, fmt.Errorf("directory %q can't have content", path)
+		}
+		files = append(files, file)
+	}
+
+	if !opts.DryRun {
+		bm.RecordUsage(name)
+	}
+
+	return files, nil
+}
+
+// checkPath checks that the path of a scaffold file is a relative path staying inside
+// the destination directory, on a single line.
+func checkPath(path string) error {
+	if strings.ContainsAny(path, "\r\n") || !filepath.IsLocal(filepath.FromSlash(path)) {
+		return fmt.Errorf("invalid file path %q: expected a relative path inside the destination", path)
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	return strings.HasSuffix(path, "/")
+}
+
+// WrittenFile is a file or directory written by WriteFiles.
+type WrittenFile struct {
+	// Path is the path of the file, joined to the destination directory.
+	Path string
+	// Overwritten reports whether the file already existed.
+	Overwritten bool
+}
+
+// WriteFiles writes the files of an expanded scaffold to the directory dest,
+// creating it and the missing parent directories. Existing files are only
+// overwritten if force is set: otherwise, nothing is written and the error,
+// wrapping ErrFileExists, lists them. Existing directories are left as-is.
+// Files are created with mode 0644, or 0600 when private, overwritten private files included.
+// It returns the files written and the directories created, in order.
+func WriteFiles(dest string, files []File, force bool) ([]WrittenFile, error) {
+	var existing []string
+	for _, file := range files {
+		if err := checkPath(file.Path); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dest, filepath.FromSlash(file.Path))
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case isDir(file.Path) && !info.IsDir():
+			return nil, fmt.Errorf("%s already exists and is not a directory", path)
+		case info.IsDir():
+			if !isDir(file.Path) {
+				return nil, fmt.Errorf("%s already exists and is a directory", path)
+			}
+		default:
+			existing = append(existing, path)
+		}
+	}
+	if len(existing) > 0 && !force {
+		return nil, fmt.Errorf("%w: %s", ErrFileExists, strings.Join(existing, ", "))
+	}
+
+	var written []WrittenFile
+	for _, file := range files {
+		path := filepath.Join(dest, filepath.FromSlash(file.Path))
+		if isDir(file.Path) {
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return written, err
+			}
+			written = append(written, WrittenFile{Path: path})
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return written, err
+		}
+		mode := os.FileMode(0o644)
+		if file.Private {
+			mode = 0o600
+		}
+		if err := os.WriteFile(path, []byte(file.Content), mode); err != nil {
+			return written, err
+		}
+		// WriteFile keeps the mode of the files it overwrites.
+		if file.Private {
+			if err := os.Chmod(path, mode); err != nil {
+				return written, err
+			}
+		}
+		written = append(written, WrittenFile{Path: path, Overwritten: slices.Contains(existing, path)})
+	}
+
+	return written, nil
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driquet/ezbp/internal/boilerplate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScaffold = `==> {{Name}}/go.mod <==
+module example.com/{{Name}}
+==> {{Name}}/README.md <==
+# {{Name}}
+
+[[license]]
+==> {{#if Tests == "yes"}}{{Name}}/{{Name}}_test.go{{/if}} <==
+package {{Name}}
+==> {{Name}}/internal/ <==
+`
+
+func TestScaffold(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Name": "demo", "Tests": "no"}}
+	bm := newTestEngine(t, u, map[string]string{
+		"module":  testScaffold,
+		"license": "MIT",
+	})
+	editBoilerplate(t, bm, "module", func(bp *boilerplate.Boilerplate) { bp.Scaffold = true })
+
+	files, err := bm.Scaffold("module", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []File{
+		{Path: "demo/go.mod", Content: "module example.com/demo\n"},
+		{Path: "demo/README.md", Content: "# demo\n\nMIT\n"},
+		{Path: "demo/internal/", Content: ""},
+	}, files, "files whose path is empty are skipped")
+	assert.Equal(t, [][]string{{"Name", "Tests"}}, u.forms, "variables are asked once for all files")
+
+	bp, _ := bm.Get("module")
+	assert.Equal(t, 1, bp.Count)
+
+	// Scaffolds can't be expanded as text.
+	_, err = bm.Expand("module")
+	assert.EqualError(t, err, `boilerplate "module" is a scaffold, which can't be expanded as text`)
+	_, err = bm.Scaffold("license", ExpandOptions{})
+	assert.EqualError(t, err, `boilerplate "license" is not a scaffold`)
+}
+
+func TestScaffold_Errors(t *testing.T) {
+	for value, wantErr := range map[string]string{
+		"Hello\n==> a <==\n":                 `invalid scaffold "bp": line 1: content before the first ==> path <== header`,
+		"\n":                                 `invalid scaffold "bp": no ==> path <== header`,
+		"==> {{Path}} <==\n":                 `invalid file path "../etc/passwd"`,
+		"==> /{{Path}} <==\n":                `invalid file path "/../etc/passwd"`,
+		"==> a <==\n==> a <==\n":             `duplicate file path "a"`,
+		"==> a/ <==\nb\n":                    `directory "a/" can't have content`,
+		"==> a <==\n{{#if x}}\n==> b <==":    `unable to parse scaffold "bp": 2:1: unclosed {{#if}} block`,
+		"==> a <==\n==>  b/{{ | upper}} <==": `unable to parse scaffold "bp": 2:8: missing prompt`,
+	} {
+		bm := newTestEngine(t, &fakeUI{answers: map[string]string{"Path": "../etc/passwd"}}, map[string]string{"bp": value})
+		bm.boilerplates["bp"].Scaffold = true
+
+		_, err := bm.Scaffold("bp", ExpandOptions{})
+		assert.ErrorContains(t, err, wantErr, value)
+	}
+
+	bm := newTestEngine(t, &fakeUI{}, map[string]string{"bp": "Hello"})
+	err := bm.EditBoilerplate(&boilerplate.Boilerplate{Name: "bp", Value: "Hello", Scaffold: true})
+	assert.ErrorContains(t, err, "content before the first ==> path <== header")
+
+	// Invalid scaffolds are neither added nor edited.
+	err = bm.AddBoilerplate(&boilerplate.Boilerplate{Name: "tree", Value: "not a tree", Scaffold: true})
+	assert.ErrorContains(t, err, `invalid scaffold "tree"`)
+	assert.False(t, bm.Exist("tree"))
+
+	require.NoError(t, bm.AddBoilerplate(&boilerplate.Boilerplate{Name: "tree", Value: testScaffold, Scaffold: true}))
+	assert.ErrorContains(t, bm.Edit("tree", "not a tree"), `invalid scaffold "tree"`)
+	bp, _ := bm.Get("tree")
+	assert.Equal(t, testScaffold, bp.Value)
+
+	err = bm.EditBoilerplate(&boilerplate.Boilerplate{Name: "bp", Value: "still not a tree", Scaffold: true})
+	assert.ErrorContains(t, err, `invalid scaffold "bp"`)
+	bp, _ = bm.Get("bp")
+	assert.Equal(t, &boilerplate.Boilerplate{Name: "bp", Value: "Hello"}, bp)
+}
+
+func TestScaffold_Secrets(t *testing.T) {
+	u := &fakeUI{answers: map[string]string{"Token": "s3cr3t"}}
+	bm := newTestEngine(t, u, map[string]string{"env": "==> .env <==\nTOKEN={{Token:secret}}\n==> data/ <==\n"})
+	editBoilerplate(t, bm, "env", func(bp *boilerplate.Boilerplate) { bp.Scaffold = true })
+
+	files, err := bm.Scaffold("env", ExpandOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []File{
+		{Path: ".env", Content: "TOKEN=s3cr3t\n", Private: true},
+		{Path: "data/"},
+	}, files)
+
+	u.answers["Token"] = ""
+	files, err = bm.Scaffold("env", ExpandOptions{})
+	require.NoError(t, err)
+	assert.False(t, files[0].Private, "files are private only when a secret was given")
+}
+
+func TestWriteFiles(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "project")
+	files := []File{
+		{Path: "cmd/main.go", Content: "package main\n"},
+		{Path: "internal/"},
+	}
+
+	written, err := WriteFiles(dest, files, false)
+	require.NoError(t, err)
+	assert.Equal(t, []WrittenFile{
+		{Path: filepath.Join(dest, "cmd", "main.go")},
+		{Path: filepath.Join(dest, "internal")},
+	}, written)
+	content, err := os.ReadFile(filepath.Join(dest, "cmd", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+	assert.DirExists(t, filepath.Join(dest, "internal"))
+
+	// Existing files are not overwritten, unless forced.
+	files[0].Content = "package other\n"
+	files = append(files, File{Path: "README.md", Content: "# project\n"})
+	_, err = WriteFiles(dest, files, false)
+	assert.ErrorIs(t, err, ErrFileExists)
+	assert.ErrorContains(t, err, filepath.Join(dest, "cmd", "main.go"))
+	assert.NoFileExists(t, filepath.Join(dest, "README.md"), "nothing is written if a file exists")
+
+	written, err = WriteFiles(dest, files, true)
+	require.NoError(t, err)
+	assert.Equal(t, []WrittenFile{
+		{Path: filepath.Join(dest, "cmd", "main.go"), Overwritten: true},
+		{Path: filepath.Join(dest, "README.md")},
+	}, written, "existing directories are not reported")
+	content, err = os.ReadFile(filepath.Join(dest, "cmd", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package other\n", string(content))
+
+	// Private files are only readable by their owner, even when overwritten.
+	written, err = WriteFiles(dest, []File{{Path: "README.md", Content: "token\n", Private: true}}, true)
+	require.NoError(t, err)
+	info, err := os.Stat(written[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	_, err = WriteFiles(dest, []File{{Path: "cmd"}}, true)
+	assert.ErrorContains(t, err, "already exists and is a directory")
+	_, err = WriteFiles(dest, []File{{Path: "../outside"}}, true)
+	assert.ErrorContains(t, err, "invalid file path")
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sourceFunc lists the choices provided by a choice source.
+type sourceFunc func(ex *expansion, src choiceSource) ([]string, error)
+
+// choiceSources are the sources of choices computed at expansion time,
+// as in "{{Assignee|@boilerplate:team_members}}".
+var choiceSources = map[string]sourceFunc{
+	"boilerplate": boilerplateSource,
+	"file":        fileSource,
+	"cmd":         commandSource,
+}
+
+// choiceSource is a choice of a choiceNode replaced by a list of choices when asked.
+type choiceSource struct {
+	kind string
+	// arg holds whatever follows the source kind in the choice, as in "@file:arg".
+	arg string
+	// trusted is set when the source belongs to a trusted boilerplate.
+	trusted bool
+}
+
+// parseChoiceSource parses a choice such as "@file:~/services.txt".
+// ok is false if the choice doesn't start with "@" followed by a known source kind,
+// in which case it is a literal choice. A double-quoted argument is unquoted.
+func parseChoiceSource(choice string) (src choiceSource, ok bool) {
+	rest, found := strings.CutPrefix(choice, "@")
+	if !found {
+		return choiceSource{}, false
+	}
+	kind, arg, found := strings.Cut(rest, ":")
+	if _, known := choiceSources[kind]; !found || !known {
+		return choiceSource{}, false
+	}
+
+	arg = strings.TrimSpace(arg)
+	if unquoted, err := strconv.Unquote(arg); err == nil {
+		arg = unquoted
+	}
+	return choiceSource{kind: kind, arg: arg}, true
+}
+
+// choices returns the choices of a choice node, its sourced choices following the literal ones.
+func (ex *expansion) choices(n *choiceNode) ([]string, error) {
+	choices := slices.Clone(n.choices)
+	for _, src := range n.sources {
+		sourced, err := choiceSources[src.kind](ex, src)
+		if err != nil {
+			return nil, fmt.Errorf("choices of %q from @%s:%s: %w", n.label, src.kind, src.arg, err)
+		}
+		choices = append(choices, sourced...)
+	}
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("no choices for %q", n.label)
+	}
+	return choices, nil
+}
+
+// boilerplateSource lists the lines of a boilerplate, which is not expanded.
+func boilerplateSource(ex *expansion, src choiceSource) ([]string, error) {
+	bp, found := ex.bm.boilerplates[src.arg]
+	if !found {
+		return nil, fmt.Errorf("unknown referenced boilerplate %q", src.arg)
+	}
+	return lines(bp.Value), nil
+}
+
+// fileSource lists the lines of a file. A leading "~/" stands for the home directory.
+func fileSource(ex *expansion, src choiceSource) ([]string, error) {
+	path, err := expandHome(src.arg)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return lines(string(content)), nil
+}
+
+// commandSource lists the lines printed by a shell command, which must be
+// allowed like command placeholders.
+func commandSource(ex *expansion, src choiceSource) ([]string, error) {
+	output, err := ex.runCommand(src.arg, src.trusted)
+	if err != nil {
+		return nil, err
+	}
+	return lines(output), nil
+}
+
+// lines splits s into its non-empty lines, trimmed.
+func lines(s string) []string {
+	var result []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
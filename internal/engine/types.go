@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promptTypes validate the answers of typed prompts, as in "{{Count:int}}", by type name.
+var promptTypes = map[string]func(value string) error{
+	"int":    validateInt,
+	"date":   validateDate,
+	"email":  validateEmail,
+	"text":   nil, // multi-line, free-form answers
+	"secret": nil, // masked, free-form answers
+}
+
+// promptType constrains the answer of a prompt to a type, or to a regular expression.
+type promptType struct {
+	name    string
+	pattern *regexp.Regexp // set for "/regexp/" types, whose name is empty
+}
+
+// cutType removes the type suffix of a prompt head, as in "Count:int".
+// typ is nil if there is no type suffix. Regular expressions are removed
+// beforehand by cutPattern.
+func cutType(head string) (rest string, typ *promptType) {
+	head = strings.TrimSpace(head)
+	if idx := strings.LastIndexByte(head, ':'); idx >= 0 {
+		if _, found := promptTypes[head[idx+1:]]; found {
+			return head[:idx], &promptType{name: head[idx+1:]}
+		}
+	}
+	return head, nil
+}
+
+// cutPattern removes the regular expression type of a placeholder, as in
+// "Env:/^(dev|prod)$/=dev | upper", before the placeholder is split on pipes and
+// equal signs, which the expression may contain. The expression starts at the first
+// ":/" not starting a URL ("://") of the label, and ends at the first slash followed by
+// the end of the placeholder, a default value or a pipe. typ is nil if there is no
+// such expression, and err is set if the expression doesn't compile.
+func cutPattern(s string) (rest string, typ *promptType, err error) {
+	start := -1
+	for i := 0; i+2 < len(s) && s[i] != '=' && s[i] != '|'; i++ {
+		if s[i] == ':' && s[i+1] == '/' && s[i+2] != '/' {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return s, nil, nil
+	}
+
+	for end := start + 2; end < len(s); end++ {
+		if s[end] != '/' {
+			continue
+		}
+		if after := strings.TrimSpace(s[end+1:]); after != "" && after[0] != '=' && after[0] != '|' {
+			continue
+		}
+		pattern, err := regexp.Compile(s[start+2 : end])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return s[:start] + s[end+1:], &promptType{pattern: pattern}, nil
+	}
+	return s, nil, nil
+}
+
+// validate returns an error describing why value doesn't match the type.
+func (t *promptType) validate(value string) error {
+	if t.pattern != nil {
+		if !t.pattern.MatchString(value) {
+			return fmt.Errorf("must match /%s/", t.pattern)
+		}
+		return nil
+	}
+	if validate := promptTypes[t.name]; validate != nil {
+		return validate(value)
+	}
+	return nil
+}
+
+func validateInt(value string) error {
+	if _, err := strconv.Atoi(strings.TrimSpace(value)); err != nil {
+		return errors.New("must be an integer")
+	}
+	return nil
+}
+
+func validateDate(value string) error {
+	if _, err := time.Parse("2006-01-02", strings.TrimSpace(value)); err != nil {
+		return errors.New("must be a date (YYYY-MM-DD)")
+	}
+	return nil
+}
+
+func validateEmail(value string) error {
+	addr, err := mail.ParseAddress(strings.TrimSpace(value))
+	if err != nil || addr.Name != "" {
+		return errors.New("must be an email address")
+	}
+	return nil
+}
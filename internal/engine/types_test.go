@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCutType(t *testing.T) {
+	for _, tc := range []struct {
+		head string
+		rest string
+		typ  *promptType
+	}{
+		{"Count", "Count", nil},
+		{"Count:int", "Count", &promptType{name: "int"}},
+		{"due: Due date:date", "due: Due date", &promptType{name: "date"}},
+		{"name: Your name", "name: Your name", nil},
+		{"Time:unknown", "Time:unknown", nil},
+		{"Description:text", "Description", &promptType{name: "text"}},
+		{"token: API token:secret", "token: API token", &promptType{name: "secret"}},
+	} {
+		rest, typ := cutType(tc.head)
+		assert.Equal(t, tc.rest, rest, tc.head)
+		assert.Equal(t, tc.typ, typ, tc.head)
+	}
+}
+
+func TestCutPattern(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		rest string
+		typ  *promptType
+	}{
+		{"Count:int", "Count:int", nil},
+		{`Ticket:/^[A-Z]+-\d+$/`, "Ticket", &promptType{pattern: regexp.MustCompile(`^[A-Z]+-\d+$`)}},
+		{"Path:/a:/b/", "Path", &promptType{pattern: regexp.MustCompile("a:/b")}},
+		{"Path:/a/b/ = a/b", "Path = a/b", &promptType{pattern: regexp.MustCompile("a/b")}},
+		{"Env:/^(dev|prod)$/ | upper", "Env | upper", &promptType{pattern: regexp.MustCompile("^(dev|prod)$")}},
+		{"Docs: https://example.com/", "Docs: https://example.com/", nil},
+		{"Site:/home", "Site:/home", nil},
+		{"Path=a:/b/", "Path=a:/b/", nil},
+		{"Choice|a:/b/", "Choice|a:/b/", nil},
+	} {
+		rest, typ, err := cutPattern(tc.s)
+		require.NoError(t, err, tc.s)
+		assert.Equal(t, tc.rest, rest, tc.s)
+		assert.Equal(t, tc.typ, typ, tc.s)
+	}
+
+	_, _, err := cutPattern("Ticket:/[/")
+	assert.ErrorContains(t, err, "invalid regular expression")
+}
+
+func TestPromptType_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		typ   *promptType
+		value string
+		err   string
+	}{
+		{&promptType{name: "int"}, "42", ""},
+		{&promptType{name: "int"}, "-3 ", ""},
+		{&promptType{name: "int"}, "4.2", "must be an integer"},
+		{&promptType{name: "date"}, "2024-02-29", ""},
+		{&promptType{name: "date"}, "2023-02-29", "must be a date (YYYY-MM-DD)"},
+		{&promptType{name: "email"}, "alice@example.com", ""},
+		{&promptType{name: "email"}, "Alice <alice@example.com>", "must be an email address"},
+		{&promptType{name: "email"}, "alice", "must be an email address"},
+		{&promptType{name: "text"}, "line 1\nline 2", ""},
+		{&promptType{pattern: regexp.MustCompile(`^[A-Z]+-\d+$`)}, "OPS-12", ""},
+		{&promptType{pattern: regexp.MustCompile(`^[A-Z]+-\d+$`)}, "ops-12", `must match /^[A-Z]+-\d+$/`},
+	} {
+		err := tc.typ.validate(tc.value)
+		if tc.err == "" {
+			assert.NoError(t, err, tc.value)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.value)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/driquet/ezbp/internal/editor"
+)
+
+// Field is a question of a form.
+// Its answer is written to Value, or to Values for multi-select fields,
+// whose initial content is the default answer.
+type Field struct {
+	// Prompt is the question displayed to the user.
+	Prompt string
+	// Choices, if not empty, restricts the answer to one of them.
+	Choices []string
+	// Multiple allows selecting several choices, written to Values.
+	Multiple bool
+	// Multiline, Secret and Validate are the options of free-form fields, see PromptOptions.
+	Multiline bool
+	Secret    bool
+	Validate  func(string) error
+
+	Value  *string
+	Values *[]string
+}
+
+// Preview renders the result of a form while it is being answered, filled with the answers
+// given so far. pending formats the prompts of the fields not answered yet, to highlight them.
+type Preview func(pending func(prompt string) string) string
+
+// AskFields asks the fields of a form one by one, using the Select, MultiSelect and Prompt
+// methods of a UI. It is meant for UIs which can't display a whole form at once.
+// Invalid answers are asked again, the validation error being displayed next to the prompt,
+// as these UIs don't validate answers themselves.
+func AskFields(u UI, fields []Field) error {
+	for _, field := range fields {
+		var err error
+		switch {
+		case field.Multiple:
+			*field.Values, err = u.MultiSelect(field.Prompt, field.Choices, *field.Values)
+		case len(field.Choices) > 0:
+			*field.Value, err = u.Select(field.Prompt, field.Choices, *field.Value)
+		default:
+			*field.Value, err = askPrompt(u, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// askPrompt asks a free-form field until its answer is valid.
+func askPrompt(u UI, field Field) (string, error) {
+	opts := PromptOptions{
+		Default:   *field.Value,
+		Multiline: field.Multiline,
+		Secret:    field.Secret,
+		Validate:  field.Validate,
+	}
+	prompt := field.Prompt
+	for {
+		value, err := u.Prompt(prompt, opts)
+		if err != nil || field.Validate == nil {
+			return value, err
+		}
+		err = field.Validate(value)
+		if err == nil {
+			return value, nil
+		}
+		prompt = fmt.Sprintf("%s (%v)", field.Prompt, err)
+		opts.Default = value
+	}
+}
+
+// huhFields turns the fields of a form into huh fields, in a single group so that
+// the user can go back and forth between them. editorCmd is the editor opened with
+// ctrl+e in multi-line fields, see editor.DefaultEditor.
+func huhFields(fields []Field, editorCmd string) []huh.Field {
+	var result []huh.Field
+	for _, field := range fields {
+		switch {
+		case field.Multiple:
+			result = append(result, huh.NewMultiSelect[string]().
+				Title(field.Prompt).
+				Value(field.Values).
+				Options(huh.NewOptions(field.Choices...)...))
+
+		case len(field.Choices) > 0:
+			result = append(result, huh.NewSelect[string]().
+				Title(field.Prompt).
+				Value(field.Value).
+				Options(huh.NewOptions(field.Choices...)...))
+
+		case field.Multiline:
+			text := huh.NewText().
+				Title(field.Prompt).
+				Description("ctrl+e: open editor").
+				Editor(strings.Fields(editor.DefaultEditor(editorCmd))...).
+				Value(field.Value)
+			if field.Validate != nil {
+				text = text.Validate(field.Validate)
+			}
+			result = append(result, text)
+
+		default:
+			input := huh.NewInput().
+				Title(field.Prompt).
+				Password(field.Secret).
+				Value(field.Value)
+			if field.Validate != nil {
+				input = input.Validate(field.Validate)
+			}
+			result = append(result, input)
+		}
+	}
+	return result
+}
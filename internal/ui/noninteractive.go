@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/driquet/ezbp/internal/boilerplate"
+)
+
+// ErrNoDefault is returned by the non-interactive UI when a question has no default value.
+var ErrNoDefault = errors.New("no default value")
+
+// NonInteractiveUI implements the UI interface without any user interaction.
+// Every question is answered with its default value.
+type NonInteractiveUI struct{}
+
+// NewNonInteractiveUI creates a new NonInteractiveUI instance.
+func NewNonInteractiveUI() UI {
+	return &NonInteractiveUI{}
+}
+
+// SelectBoilerplate implements the UI interface method for selecting a boilerplate.
+// A boilerplate can't be chosen without interaction, so it always fails.
+func (u *NonInteractiveUI) SelectBoilerplate(boilerplates map[string]*boilerplate.Boilerplate) (string, error) {
+	return "", errors.New("a boilerplate name is required in non-interactive mode")
+}
+
+// Select implements the UI interface method for selecting from a list of choices.
+// It returns the default value, or ErrNoDefault if there is none.
+func (u *NonInteractiveUI) Select(prompt string, choices []string, defaultValue string) (string, error) {
+	if defaultValue == "" {
+		return "", fmt.Errorf("%q: %w", prompt, ErrNoDefault)
+	}
+	return defaultValue, nil
+}
+
+// MultiSelect implements the UI interface method for selecting several choices.
+// It returns the default values, or ErrNoDefault if there are none.
+func (u *NonInteractiveUI) MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error) {
+	if len(defaultValues) == 0 {
+		return nil, fmt.Errorf("%q: %w", prompt, ErrNoDefault)
+	}
+	return defaultValues, nil
+}
+
+// Prompt implements the UI interface method for prompting the user for input.
+// It returns the default value, or ErrNoDefault if there is none.
+// An invalid default value is an error, as it can't be corrected. Secret values are not
+// included in errors.
+func (u *NonInteractiveUI) Prompt(prompt string, opts PromptOptions) (string, error) {
+	if opts.Default == "" {
+		return "", fmt.Errorf("%q: %w", prompt, ErrNoDefault)
+	}
+	if opts.Validate != nil {
+		if err := opts.Validate(opts.Default); err != nil {
+			if opts.Secret {
+				return "", fmt.Errorf("%q: invalid default value: %w", prompt, err)
+			}
+			return "", fmt.Errorf("%q: invalid default value %q: %w", prompt, opts.Default, err)
+		}
+	}
+	return opts.Default, nil
+}
+
+// Form implements the UI interface method for answering a form.
+// Each field is answered with its default value, see Select, MultiSelect and Prompt.
+// The preview is not displayed.
+func (u *NonInteractiveUI) Form(fields []Field, preview Preview) error {
+	return AskFields(u, fields)
+}
+
+// Review implements the UI interface method for reviewing a result.
+// The result is printed to stdout and discarded, as there is no one to accept it.
+func (u *NonInteractiveUI) Review(result string, accept string) (ReviewAction, error) {
+	fmt.Println(result)
+	return ReviewCancel, nil
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minSplitWidth is the terminal width below which the preview is displayed below the form.
+const minSplitWidth = 80
+
+// formPreviewModel is the Bubble Tea model displaying a form next to the preview of its result.
+type formPreviewModel struct {
+	form    *huh.Form
+	preview Preview
+	width   int
+	height  int
+}
+
+// runFormWithPreview runs a form, displaying the preview next to it.
+func runFormWithPreview(form *huh.Form, preview Preview) error {
+	program := tea.NewProgram(&formPreviewModel{form: form, preview: preview}, tea.WithAltScreen())
+	finalModel, err := program.Run()
+	if err != nil {
+		return err
+	}
+	if finalModel.(*formPreviewModel).form.State == huh.StateAborted {
+		return ErrUserAborted
+	}
+	return nil
+}
+
+func (m *formPreviewModel) Init() tea.Cmd {
+	return m.form.Init()
+}
+
+func (m *formPreviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width = size.Width
+		m.height = size.Height
+		if m.split() {
+			// The form gets the left half of the screen.
+			size.Width /= 2
+		}
+		msg = size
+	}
+
+	model, cmd := m.form.Update(msg)
+	m.form = model.(*huh.Form)
+
+	// The form quits the program when run by itself only.
+	if m.form.State != huh.StateNormal {
+		return m, tea.Quit
+	}
+	return m, cmd
+}
+
+// split reports whether the preview is displayed next to the form, rather than below it.
+func (m *formPreviewModel) split() bool {
+	return m.width >= minSplitWidth
+}
+
+func (m *formPreviewModel) View() string {
+	if m.form.State != huh.StateNormal {
+		return ""
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true)
+
+	pendingStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("212")).
+		Background(lipgloss.Color("57"))
+
+	borderStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62"))
+
+	form := m.form.View()
+	pending := func(prompt string) string {
+		return pendingStyle.Render("‹" + prompt + "›")
+	}
+	content := strings.TrimRight(m.preview(pending), "\n")
+
+	if !m.split() {
+		preview := borderStyle.
+			Width(max(m.width-2, 0)).
+			MaxHeight(max(m.height-lipgloss.Height(form)-1, 0)).
+			Render(content)
+		return lipgloss.JoinVertical(lipgloss.Left, form, titleStyle.Render("Preview:"), preview)
+	}
+
+	previewWidth := m.width - m.width/2 - 4 // 2 for borders, 2 for spacing
+	preview := borderStyle.
+		Width(previewWidth).
+		MaxHeight(max(m.height-1, 0)).
+		Render(content)
+	form = lipgloss.NewStyle().Width(m.width / 2).Render(form)
+
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		form,
+		"  ",
+		lipgloss.JoinVertical(lipgloss.Left, titleStyle.Render("Preview:"), preview),
+	)
+}
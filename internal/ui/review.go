@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ReviewAction is what to do with the result of an expansion, see UI.Review.
+type ReviewAction int
+
+const (
+	ReviewAccept   ReviewAction = iota // use the result, e.g. copy it to the clipboard
+	ReviewEdit                         // edit the result, then review it again
+	ReviewReanswer                     // answer the prompts again
+	ReviewCancel                       // discard the result
+)
+
+// reviewChoices are the labels of the review actions, for UIs selecting them in a list.
+func reviewChoices(accept string) []string {
+	return []string{accept, "edit", "re-answer", "cancel"}
+}
+
+// reviewAction returns the review action labeled choice, see reviewChoices.
+func reviewAction(accept string, choice string) (ReviewAction, error) {
+	idx := slices.Index(reviewChoices(accept), choice)
+	if idx < 0 {
+		return ReviewCancel, fmt.Errorf("unexpected review action %q", choice)
+	}
+	return ReviewAction(idx), nil
+}
+
+// reviewModel is the Bubble Tea model of the TerminalUI confirmation screen,
+// showing the result of an expansion in a scrollable viewport.
+type reviewModel struct {
+	result   string
+	accept   string
+	action   ReviewAction
+	viewport viewport.Model
+	ready    bool
+}
+
+func newReviewModel(result string, accept string) *reviewModel {
+	return &reviewModel{
+		result:   result,
+		accept:   accept,
+		action:   ReviewCancel,
+		viewport: viewport.New(0, 0),
+	}
+}
+
+func (m *reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.viewport.Width = msg.Width - 2   // 2 for borders
+		m.viewport.Height = msg.Height - 5 // Reserve space for title, borders and instructions
+		if !m.ready {
+			m.ready = true
+			m.viewport.SetContent(m.result)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.action = ReviewCancel
+			return m, tea.Quit
+
+		case "enter", "c":
+			m.action = ReviewAccept
+			return m, tea.Quit
+
+		case "e":
+			m.action = ReviewEdit
+			return m, tea.Quit
+
+		case "r":
+			m.action = ReviewReanswer
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m *reviewModel) View() string {
+	if !m.ready {
+		return "\n  Initializing..."
+	}
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240"))
+
+	borderStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62"))
+
+	instructions := strings.Join([]string{
+		"enter/c: " + m.accept,
+		"e: edit",
+		"r: re-answer",
+		"q/esc: cancel",
+		"↑/↓: scroll",
+	}, " • ")
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Result:"),
+		borderStyle.Render(m.viewport.View()),
+		normalStyle.Render(instructions),
+	)
+}
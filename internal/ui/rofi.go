@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"html"
 	"os/exec"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/driquet/ezbp/internal/boilerplate"
@@ -21,6 +24,9 @@ type RofiConfig struct {
 	SelectArgs []string `toml:"select_args,omitempty"`
 	// InputArgs are extra arguments to pass to Rofi when used for free-form text input.
 	InputArgs []string `toml:"input_args,omitempty"`
+	// Editor is a graphical editor command used for multi-line prompts, e.g. "gedit" or "code --wait".
+	// If empty, multi-line answers are typed on a single line, "\n" standing for a new line.
+	Editor string `toml:"editor,omitempty"`
 }
 
 // RofiUI implements the UI interface using Rofi for user interactions.
@@ -34,8 +40,26 @@ func NewRofiUI(config RofiConfig) UI {
 }
 
 // runRofi executes a Rofi command with the given arguments and input string.
-// It returns the selected string or an error.
+// It returns the selected string or an error. An empty selection among a non-empty
+// input is a cancellation.
 func (u *RofiUI) runRofi(prompt string, input string, args []string) (string, error) {
+	selected, err := u.execRofi(prompt, input, args)
+	if err != nil {
+		return "", err
+	}
+
+	// If Rofi was cancelled in a way that results in a 0 exit code but empty output (less common),
+	// also treat as cancellation.
+	if selected == "" && input != "" { // only consider empty output as cancellation if there was input to select from
+		return "", ErrUserAborted
+	}
+
+	return selected, nil
+}
+
+// execRofi executes a Rofi command as runRofi does, an empty selection being returned as is.
+// Only the exit code of Esc is a cancellation.
+func (u *RofiUI) execRofi(prompt string, input string, args []string) (string, error) {
 	cmdArgs := []string{"-dmenu"}
 	if prompt != "" {
 		cmdArgs = append(cmdArgs, "-p", prompt)
@@ -69,14 +93,7 @@ func (u *RofiUI) runRofi(prompt string, input string, args []string) (string, er
 		return "", fmt.Errorf("rofi command failed: %w\nStderr: %s", err, stderr.String())
 	}
 
-	selected := strings.TrimSpace(stdout.String())
-	// If Rofi was cancelled in a way that results in a 0 exit code but empty output (less common),
-	// also treat as cancellation.
-	if selected == "" && input != "" { // only consider empty output as cancellation if there was input to select from
-		return "", ErrUserAborted
-	}
-
-	return selected, nil
+	return strings.TrimSpace(stdout.String()), nil
 }
 
 // SelectBoilerplate implements the UI interface method for selecting a boilerplate using Rofi.
@@ -117,25 +134,107 @@ func (u *RofiUI) SelectBoilerplate(boilerplates map[string]*boilerplate.Boilerpl
 }
 
 // Select implements the UI interface method for selecting from a list of choices using Rofi.
-func (u *RofiUI) Select(prompt string, choices []string) (string, error) {
+// The default value is preselected using Rofi's -selected-row option.
+func (u *RofiUI) Select(prompt string, choices []string, defaultValue string) (string, error) {
 	if len(choices) == 0 {
 		return "", fmt.Errorf("no choices provided for selection")
 	}
 	rofiInput := strings.Join(choices, "\n")
-	return u.runRofi(prompt, rofiInput, u.config.SelectArgs)
+
+	args := u.config.SelectArgs
+	if idx := slices.Index(choices, defaultValue); defaultValue != "" && idx >= 0 {
+		args = append([]string{"-selected-row", strconv.Itoa(idx)}, args...)
+	}
+	return u.runRofi(prompt, rofiInput, args)
+}
+
+// MultiSelect implements the UI interface method for selecting several choices using Rofi's -multi-select mode.
+// Rofi can't preselect several rows, so the cursor is only placed on the first default value.
+// Accepting an empty selection answers none of the choices, only Esc cancels.
+func (u *RofiUI) MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error) {
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("no choices provided for selection")
+	}
+	rofiInput := strings.Join(choices, "\n")
+
+	args := append([]string{"-multi-select"}, u.config.SelectArgs...)
+	if len(defaultValues) > 0 {
+		if idx := slices.Index(choices, defaultValues[0]); idx >= 0 {
+			args = append([]string{"-selected-row", strconv.Itoa(idx)}, args...)
+		}
+	}
+	selected, err := u.execRofi(prompt, rofiInput, args)
+	if err != nil {
+		return nil, err
+	}
+	if selected == "" {
+		return nil, nil
+	}
+	return strings.Split(selected, "\n"), nil
 }
 
 // Prompt implements the UI interface method for prompting the user for input using Rofi.
-func (u *RofiUI) Prompt(prompt string) (string, error) {
+// The input is pre-filled with the default value using Rofi's -filter option, except for secrets.
+// Multi-line prompts open the configured editor, or accept "\n" escapes if there is none.
+// Secret prompts are masked with Rofi's -password option. Answers are not validated.
+func (u *RofiUI) Prompt(prompt string, opts PromptOptions) (string, error) {
+	if opts.Multiline {
+		if u.config.Editor != "" {
+			return editText(u.config.Editor, opts.Default)
+		}
+		args := append([]string{"-mesg", `Multi-line answer: type \n for a new line`}, u.config.InputArgs...)
+		if opts.Default != "" {
+			args = append([]string{"-filter", strings.ReplaceAll(opts.Default, "\n", `\n`)}, args...)
+		}
+		response, err := u.runRofi(prompt, "", args)
+		if err != nil {
+			return "", err
+		}
+		return strings.ReplaceAll(response, `\n`, "\n"), nil
+	}
+
+	defaultValue := opts.Default
 	// For text input, Rofi's dmenu typically expects no stdin, or specific flags.
 	// We pass an empty input string and rely on runRofi's handling for input mode.
 	// Additional args for input mode are taken from u.config.InputArgs.
-	response, err := u.runRofi(prompt, "", u.config.InputArgs)
+	args := u.config.InputArgs
+	if opts.Secret {
+		// The default isn't pre-filled, as it would be visible in the command line of Rofi,
+		// but an empty answer keeps it.
+		args = append([]string{"-password"}, args...)
+		if defaultValue != "" {
+			args = append([]string{"-mesg", "Leave empty to keep the default value"}, args...)
+		}
+	} else if defaultValue != "" {
+		args = append([]string{"-filter", defaultValue}, args...)
+	}
+	response, err := u.runRofi(prompt, "", args)
 	if err != nil {
 		return "", err
 	}
+	if opts.Secret && response == "" {
+		return defaultValue, nil
+	}
 	// If Rofi input is cancelled (e.g. Esc), runRofi should return ErrUserAborted.
 	// If it returns empty string for other reasons (e.g. user just hits enter),
 	// it's still a valid (empty) input.
 	return response, nil
 }
+
+// Form implements the UI interface method for answering a form, one Rofi prompt after the other.
+// The preview is not displayed.
+func (u *RofiUI) Form(fields []Field, preview Preview) error {
+	return AskFields(u, fields)
+}
+
+// Review implements the UI interface method for reviewing a result using Rofi,
+// the result being displayed as the message of the action selection.
+func (u *RofiUI) Review(result string, accept string) (ReviewAction, error) {
+	// The message is Pango markup, in which the result must be escaped.
+	args := append([]string{"-mesg", html.EscapeString(result)}, u.config.SelectArgs...)
+	choice, err := u.runRofi("Result", strings.Join(reviewChoices(accept), "\n"), args)
+	if err != nil {
+		return ReviewCancel, err
+	}
+	return reviewAction(accept, choice)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"slices"
 	"sort"
 	"strings"
 
@@ -15,8 +16,10 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/driquet/ezbp/internal/boilerplate"
+	"github.com/driquet/ezbp/internal/editor"
 
 	fuzzyfinder "github.com/ktr0731/go-fuzzyfinder"
+	"golang.org/x/term"
 )
 
 // TODO: WTF is this
@@ -34,18 +37,51 @@ type UI interface {
 	SelectBoilerplate(boilerplates map[string]*boilerplate.Boilerplate) (string, error)
 
 	// Select asks the user to choose among a list of possible string choices.
-	// It takes a prompt message and a slice of choices.
+	// It takes a prompt message, a slice of choices and the choice to preselect (if not empty).
 	// It returns the selected choice or an error if the selection fails.
-	Select(prompt string, choices []string) (string, error)
+	Select(prompt string, choices []string, defaultValue string) (string, error)
+
+	// MultiSelect asks the user to choose any number of choices from a list.
+	// It takes a prompt message, a slice of choices and the choices to preselect.
+	// It returns the selected choices, in the order of the list, or an error if the selection fails.
+	MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error)
 
 	// Prompt expects an answer from the user for a given prompt message.
+	// The input is pre-filled with the default value of the options.
 	// It returns the user's input as a string or an error if reading input fails.
-	Prompt(prompt string) (string, error)
+	Prompt(prompt string, opts PromptOptions) (string, error)
+
+	// Form asks the user to answer several fields, all at once if the UI is able to.
+	// Each answer is written to the Value, or Values, of its field.
+	// UIs able to do so display the preview, if not nil, next to the form.
+	// It returns an error if answering the form fails.
+	Form(fields []Field, preview Preview) error
+
+	// Review shows the result of an expansion and asks the user what to do with it.
+	// accept describes what accepting the result does, such as "copy to clipboard".
+	// It returns the chosen action or an error if the review fails.
+	Review(result string, accept string) (ReviewAction, error)
+}
+
+// PromptOptions holds the optional settings of a prompt.
+type PromptOptions struct {
+	// Default is the initial value of the input.
+	Default string
+	// Multiline asks for a text which can span several lines, such as a stack trace.
+	Multiline bool
+	// Secret masks the input, as for passwords and tokens.
+	Secret bool
+	// Validate, if set, checks an answer and returns an error describing why it is invalid.
+	// UIs able to do so show the error to the user until the answer is valid.
+	// Others return the answer as-is, and the caller is expected to ask again.
+	Validate func(string) error
 }
 
 // FuzzyConfig holds the configuration for the Fuzzy UI.
-// Currently, it's an empty struct, but it can be extended with configuration options in the future.
-type FuzzyConfig struct{}
+type FuzzyConfig struct {
+	// Editor is the editor command used for multi-line prompts. See editor.DefaultEditor.
+	Editor string
+}
 
 // Fuzzy implements the UI interface using a fuzzy finder for selections.
 type Fuzzy struct {
@@ -100,8 +136,8 @@ func (u *Fuzzy) SelectBoilerplate(boilerplates map[string]*boilerplate.Boilerpla
 }
 
 // Select implements the UI interface method for selecting from a list of choices using a fuzzy finder.
-// It takes a prompt (though not used in the current fuzzy finder implementation) and a slice of string choices.
-func (u *Fuzzy) Select(prompt string, choices []string) (string, error) {
+// It takes a prompt and a default value (though not used in the current fuzzy finder implementation) and a slice of string choices.
+func (u *Fuzzy) Select(prompt string, choices []string, defaultValue string) (string, error) {
 	// Use the fuzzyfinder library to let the user select a choice.
 	idx, err := fuzzyfinder.Find(
 		choices, // The slice of strings to choose from.
@@ -115,18 +151,105 @@ func (u *Fuzzy) Select(prompt string, choices []string) (string, error) {
 	return choices[idx], nil
 }
 
+// MultiSelect implements the UI interface method for selecting several choices using a fuzzy finder.
+// Choices are marked with the tab key. The default values are not preselected.
+func (u *Fuzzy) MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error) {
+	idxs, err := fuzzyfinder.FindMulti(
+		choices,
+		func(i int) string {
+			return choices[i]
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select choices: %w", err)
+	}
+
+	sort.Ints(idxs)
+	selected := make([]string, 0, len(idxs))
+	for _, idx := range idxs {
+		selected = append(selected, choices[idx])
+	}
+	return selected, nil
+}
+
 // Prompt implements the UI interface method for prompting the user for input using standard input.
 // It displays the prompt message and reads a line of text from the user.
-func (u *Fuzzy) Prompt(prompt string) (string, error) {
+// The default value is displayed between brackets and returned if the user enters an empty line.
+// Multi-line prompts open the configured editor instead. Answers are not validated.
+func (u *Fuzzy) Prompt(prompt string, opts PromptOptions) (string, error) {
+	if opts.Multiline {
+		return editText(u.config.Editor, opts.Default)
+	}
+
+	defaultValue := opts.Default
+	if opts.Secret {
+		return readSecret(prompt, defaultValue)
+	}
+
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s> ", prompt)            // Display the prompt message.
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]> ", prompt, defaultValue) // Display the prompt message and the default value.
+	} else {
+		fmt.Printf("%s> ", prompt) // Display the prompt message.
+	}
 	input, err := reader.ReadString('\n') // Read input until a newline character.
 	if err != nil {
 		return "", fmt.Errorf("failed to read input: %w", err)
 	}
+	input = strings.TrimRight(input, "\r\n")
+	if strings.TrimSpace(input) == "" && defaultValue != "" {
+		return defaultValue, nil
+	}
 	return input, nil
 }
 
+// Form implements the UI interface method for answering a form, one field after the other.
+// The preview is not displayed.
+func (u *Fuzzy) Form(fields []Field, preview Preview) error {
+	return AskFields(u, fields)
+}
+
+// Review implements the UI interface method for reviewing a result, which is printed
+// before selecting the action with a fuzzy finder.
+func (u *Fuzzy) Review(result string, accept string) (ReviewAction, error) {
+	fmt.Printf("%s\n\n", result)
+
+	choice, err := u.Select("What to do with the result?", reviewChoices(accept), accept)
+	if err != nil {
+		return ReviewCancel, err
+	}
+	return reviewAction(accept, choice)
+}
+
+// readSecret reads a line from the terminal without echoing it.
+// The default value is not displayed, but returned if the user enters an empty line.
+func readSecret(prompt string, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Printf("%s [hidden]> ", prompt)
+	} else {
+		fmt.Printf("%s> ", prompt)
+	}
+	input, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	if len(input) == 0 {
+		return defaultValue, nil
+	}
+	return string(input), nil
+}
+
+// editText opens an editor to write a multi-line text, starting from initial.
+// The final newline added by most editors is removed.
+func editText(editorCmd string, initial string) (string, error) {
+	text, err := editor.Edit(editorCmd, initial)
+	if err != nil {
+		return "", fmt.Errorf("failed to edit text: %w", err)
+	}
+	return strings.TrimSuffix(text, "\n"), nil
+}
+
 // TermUI implements the UI interface using the charmbracelet/huh library for terminal-based interactions.
 type TermUI struct{}
 
@@ -179,9 +302,9 @@ func (u *TermUI) SelectBoilerplate(boilerplates map[string]*boilerplate.Boilerpl
 }
 
 // Select implements the UI interface method for selecting from a list of choices using a terminal select prompt.
-// It uses huh.NewSelect to present the options to the user.
-func (u *TermUI) Select(prompt string, choices []string) (string, error) {
-	var value string
+// It uses huh.NewSelect to present the options to the user, with the cursor on the default value.
+func (u *TermUI) Select(prompt string, choices []string, defaultValue string) (string, error) {
+	value := defaultValue
 
 	err := huh.NewSelect[string]().
 		Title(prompt).
@@ -195,16 +318,52 @@ func (u *TermUI) Select(prompt string, choices []string) (string, error) {
 	return value, nil
 }
 
+// MultiSelect implements the UI interface method for selecting several choices using a terminal multi-select prompt.
+// It uses huh.NewMultiSelect to present the options to the user, with the default values preselected.
+func (u *TermUI) MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error) {
+	values := slices.Clone(defaultValues)
+
+	err := huh.NewMultiSelect[string]().
+		Title(prompt).
+		Value(&values).
+		Options(huh.NewOptions[string](choices...)...).
+		Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run multi-select prompt: %w", err)
+	}
+
+	return values, nil
+}
+
 // Prompt implements the UI interface method for prompting the user for input using a terminal input field.
-// It uses huh.NewInput to get input from the user.
-func (u *TermUI) Prompt(prompt string) (string, error) {
-	var value string
+// It uses huh.NewInput to get input from the user, pre-filled with the default value.
+// Validation errors are displayed below the input.
+// Multi-line prompts use huh.NewText, whose content can also be written in $EDITOR with ctrl+e.
+func (u *TermUI) Prompt(prompt string, opts PromptOptions) (string, error) {
+	value := opts.Default
+
+	if opts.Multiline {
+		text := huh.NewText().
+			Title(prompt).
+			Value(&value)
+		if opts.Validate != nil {
+			text = text.Validate(opts.Validate)
+		}
+		if err := text.Run(); err != nil {
+			return "", fmt.Errorf("failed to run text prompt: %w", err)
+		}
+		return value, nil
+	}
 
 	// Create and run a new input prompt using the huh library.
-	err := huh.NewInput().
-		Title(prompt). // Set the title of the input field.
-		Value(&value). // Store the user's input in the 'value' variable.
-		Run()
+	input := huh.NewInput().
+		Title(prompt).         // Set the title of the input field.
+		Password(opts.Secret). // Mask the input of secret prompts.
+		Value(&value)          // Store the user's input in the 'value' variable.
+	if opts.Validate != nil {
+		input = input.Validate(opts.Validate)
+	}
+	err := input.Run()
 	if err != nil {
 		return "", fmt.Errorf("failed to run input prompt: %w", err)
 	}
@@ -212,12 +371,49 @@ func (u *TermUI) Prompt(prompt string) (string, error) {
 	return value, nil
 }
 
+// Form implements the UI interface method for answering a form using a single huh form,
+// in which the user can go back to previous fields with shift+tab.
+// The preview is not displayed.
+func (u *TermUI) Form(fields []Field, preview Preview) error {
+	if err := huh.NewForm(huh.NewGroup(huhFields(fields, "")...)).Run(); err != nil {
+		return fmt.Errorf("failed to run form: %w", err)
+	}
+	return nil
+}
+
+// Review implements the UI interface method for reviewing a result using a terminal select prompt,
+// the result being displayed as the description of the prompt.
+func (u *TermUI) Review(result string, accept string) (ReviewAction, error) {
+	choices := reviewChoices(accept)
+	choice := accept
+
+	err := huh.NewSelect[string]().
+		Title("What to do with the result?").
+		Description(result).
+		Options(huh.NewOptions[string](choices...)...).
+		Value(&choice).
+		Run()
+	if err != nil {
+		return ReviewCancel, fmt.Errorf("failed to run review prompt: %w", err)
+	}
+
+	return reviewAction(accept, choice)
+}
+
+// TerminalConfig holds the configuration for the TerminalUI.
+type TerminalConfig struct {
+	// Editor is the editor command opened with ctrl+e in multi-line prompts. See editor.DefaultEditor.
+	Editor string
+}
+
 // TerminalUI implements the UI interface using Bubble Tea and Huh
-type TerminalUI struct{}
+type TerminalUI struct {
+	config TerminalConfig
+}
 
 // NewTerminalUI creates a new TerminalUI instance
-func NewTerminalUI() *TerminalUI {
-	return &TerminalUI{}
+func NewTerminalUI(config TerminalConfig) *TerminalUI {
+	return &TerminalUI{config: config}
 }
 
 // SelectBoilerplate displays boilerplates with preview using a custom Bubble Tea model
@@ -254,13 +450,13 @@ func (t *TerminalUI) SelectBoilerplate(boilerplates map[string]*boilerplate.Boil
 	return result.selectedName, nil
 }
 
-// Select uses huh.Form for simple selection
-func (t *TerminalUI) Select(prompt string, choices []string) (string, error) {
+// Select uses huh.Form for simple selection, with the cursor on the default value
+func (t *TerminalUI) Select(prompt string, choices []string, defaultValue string) (string, error) {
 	if len(choices) == 0 {
 		return "", fmt.Errorf("no choices available")
 	}
 
-	var selected string
+	selected := defaultValue
 
 	form := huh.NewForm(
 		huh.NewGroup(
@@ -278,18 +474,44 @@ func (t *TerminalUI) Select(prompt string, choices []string) (string, error) {
 	return selected, nil
 }
 
-// Prompt uses huh.Form for text input
-func (t *TerminalUI) Prompt(prompt string) (string, error) {
-	var input string
+// MultiSelect uses huh.Form for selecting several choices, with the default values preselected
+func (t *TerminalUI) MultiSelect(prompt string, choices []string, defaultValues []string) ([]string, error) {
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("no choices available")
+	}
+
+	selected := slices.Clone(defaultValues)
 
 	form := huh.NewForm(
 		huh.NewGroup(
-			huh.NewInput().
+			huh.NewMultiSelect[string]().
 				Title(prompt).
-				Value(&input),
+				Value(&selected).
+				Options(huh.NewOptions(choices...)...),
 		),
 	)
 
+	if err := form.Run(); err != nil {
+		return nil, fmt.Errorf("selection failed: %w", err)
+	}
+
+	return selected, nil
+}
+
+// Prompt uses huh.Form for text input, pre-filled with the default value.
+// Validation errors are displayed inline, and the form can't be submitted until the input is valid.
+// Multi-line prompts use a text area, whose content can also be written in the configured editor.
+func (t *TerminalUI) Prompt(prompt string, opts PromptOptions) (string, error) {
+	input := opts.Default
+
+	form := huh.NewForm(huh.NewGroup(huhFields([]Field{{
+		Prompt:    prompt,
+		Multiline: opts.Multiline,
+		Secret:    opts.Secret,
+		Validate:  opts.Validate,
+		Value:     &input,
+	}}, t.config.Editor)...))
+
 	if err := form.Run(); err != nil {
 		return "", fmt.Errorf("prompt failed: %w", err)
 	}
@@ -297,6 +519,40 @@ func (t *TerminalUI) Prompt(prompt string) (string, error) {
 	return input, nil
 }
 
+// Form displays all the fields in a single huh.Form, so that the user can go back to
+// previous answers with shift+tab before submitting them all.
+// The preview, if any, is displayed next to the form and updated as fields are answered.
+func (t *TerminalUI) Form(fields []Field, preview Preview) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	form := huh.NewForm(huh.NewGroup(huhFields(fields, t.config.Editor)...))
+
+	var err error
+	if preview == nil {
+		err = form.Run()
+	} else {
+		err = runFormWithPreview(form, preview)
+	}
+	if err != nil {
+		return fmt.Errorf("form failed: %w", err)
+	}
+
+	return nil
+}
+
+// Review displays the result in a scrollable confirmation screen, whose keys choose the action
+func (t *TerminalUI) Review(result string, accept string) (ReviewAction, error) {
+	program := tea.NewProgram(newReviewModel(result, accept), tea.WithAltScreen())
+	finalModel, err := program.Run()
+	if err != nil {
+		return ReviewCancel, fmt.Errorf("failed to run review: %w", err)
+	}
+
+	return finalModel.(*reviewModel).action, nil
+}
+
 // boilerplateSelectorModel is the Bubble Tea model for boilerplate selection with preview
 type boilerplateSelectorModel struct {
 	boilerplates  []*boilerplate.Boilerplate
@@ -1 +1,88 @@
 package ui
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonInteractiveUI(t *testing.T) {
+	u := NewNonInteractiveUI()
+
+	value, err := u.Prompt("Priority", PromptOptions{Default: "P2"})
+	require.NoError(t, err)
+	assert.Equal(t, "P2", value)
+
+	value, err = u.Select("Env", []string{"dev", "prod"}, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", value)
+
+	values, err := u.MultiSelect("Services", []string{"api", "web", "db"}, []string{"api", "db"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "db"}, values)
+
+	_, err = u.Prompt("Name", PromptOptions{})
+	assert.ErrorIs(t, err, ErrNoDefault)
+
+	_, err = u.Prompt("Count", PromptOptions{Default: "many", Validate: func(s string) error {
+		return errors.New("must be an integer")
+	}})
+	assert.EqualError(t, err, `"Count": invalid default value "many": must be an integer`)
+
+	_, err = u.Prompt("Token", PromptOptions{Default: "s3cr3t", Secret: true, Validate: func(s string) error {
+		return errors.New("too short")
+	}})
+	assert.EqualError(t, err, `"Token": invalid default value: too short`)
+
+	_, err = u.Select("Env", []string{"dev", "prod"}, "")
+	assert.ErrorIs(t, err, ErrNoDefault)
+
+	_, err = u.MultiSelect("Services", []string{"api", "web", "db"}, nil)
+	assert.ErrorIs(t, err, ErrNoDefault)
+}
+
+// fakeRofi returns a RofiUI running script instead of Rofi, and the path of the file
+// where the script's arguments are written, one per line.
+func fakeRofi(t *testing.T, script string) (UI, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	path := filepath.Join(dir, "rofi")
+	content := "#!/bin/sh\nprintf '%s\\n' \"$@\" >> " + argsPath + "\ncat > /dev/null\n" + script
+	require.NoError(t, os.WriteFile(path, []byte(content), 0700))
+	return NewRofiUI(RofiConfig{Path: path}), argsPath
+}
+
+func TestRofiUI_MultiSelect(t *testing.T) {
+	u, _ := fakeRofi(t, "printf 'api\\ndb\\n'")
+	values, err := u.MultiSelect("Services", []string{"api", "web", "db"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "db"}, values)
+
+	// An empty selection answers none of the choices.
+	u, _ = fakeRofi(t, "exit 0")
+	values, err = u.MultiSelect("Services", []string{"api", "web", "db"}, []string{"api"})
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	u, _ = fakeRofi(t, "exit 1")
+	_, err = u.MultiSelect("Services", []string{"api", "web", "db"}, nil)
+	assert.ErrorIs(t, err, ErrUserAborted)
+}
+
+func TestRofiUI_SecretPrompt(t *testing.T) {
+	u, argsPath := fakeRofi(t, "exit 0")
+	value, err := u.Prompt("Token", PromptOptions{Default: "s3cr3t", Secret: true})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value, "an empty answer keeps the default")
+
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-password")
+	assert.NotContains(t, string(args), "s3cr3t")
+}
@@ -3,10 +3,19 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/atotto/clipboard"
+	"github.com/driquet/ezbp/internal/boilerplate"
 	"github.com/driquet/ezbp/internal/database"
 	"github.com/driquet/ezbp/internal/editor"
 	"github.com/driquet/ezbp/internal/engine"
@@ -15,11 +24,30 @@ import (
 
 var (
 	ui         string
+	output     string
 	forever    bool
-	config     engine.Config
-	configPath string
-	db         database.Database
-	bm         *engine.Engine
+	raw        bool
+	trusted    bool
+	scaffold   bool
+	force      bool
+	clearAfter time.Duration
+	// dataPath, filesTemplate and jsonl are the flags of merge.
+	dataPath      string
+	filesTemplate string
+	jsonl         bool
+	// sets, answersPath and nonInteractive are the answering flags of expand.
+	sets           []string
+	answersPath    string
+	nonInteractive bool
+	dryRun         bool
+	// template and templatePath are the ad-hoc template flags of expand.
+	template     string
+	templatePath string
+	inPlace      bool
+	config       engine.Config
+	configPath   string
+	db           database.Database
+	bm           *engine.Engine
 )
 
 func setupRuntime(cmd *cobra.Command, args []string) error {
@@ -43,6 +71,17 @@ func setupRuntime(cmd *cobra.Command, args []string) error {
 	if ui != "" {
 		config.DefaultUI = ui
 	}
+	if output != "" {
+		// Check the output before asking anything, as it is only used once expanded.
+		if _, err := engine.ParseOutput(output); err != nil {
+			return err
+		}
+		config.Output = output
+	}
+	// The "none" UI can't ask anything, it expands like --non-interactive.
+	if config.DefaultUI == "none" {
+		nonInteractive = true
+	}
 
 	// Load database
 	db, err = database.NewSQLiteDatabase(config.DatabasePath)
@@ -90,13 +129,26 @@ to the EDITOR environment variable. If neither is set, a system default editor
 will be used.
 
 If both name and content are provided, the boilerplate will be created
-immediately with the specified content.`,
+immediately with the specified content.
+
+With --raw, the boilerplate is never expanded: its placeholders are kept as-is,
+which is useful to store Go templates, Jinja templates or Markdown links.
+
+With --trusted, the shell commands of the boilerplate, such as
+{{$(git branch --show-current)}}, are run when it is expanded, even if they
+are not listed in the allowed_commands configuration option.
+
+With --scaffold, the boilerplate describes a tree of files, written with
+"ezbp scaffold". See "ezbp scaffold --help" for its format.`,
 		Example: `  # Open editor to create a boilerplate interactively
   ezbp boilerplate add my-boilerplate-name
 
   # Create a boilerplate with inline content
   ezbp boilerplate add my-boilerplate-name "Hello World!"
 
+  # Create a raw boilerplate, whose placeholders are not expanded
+  ezbp boilerplate add --raw helm-value "{{ .Values.image.tag }}"
+
   # The editor priority is: config file > EDITOR env var > system default
   # Set your preferred editor:
   export EDITOR=vim
@@ -105,14 +157,24 @@ immediately with the specified content.`,
 		PreRunE:  setupRuntime,
 		PostRunE: tearDownRuntime,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			value := ""
 			if len(args) == 1 {
-				value, err := editor.Edit(config.Editor, "")
+				var err error
+				value, err = editor.Edit(config.Editor, "")
 				if err != nil {
 					return err
 				}
-				return bm.Add(args[0], value)
+			} else {
+				value = args[1]
 			}
-			return bm.Add(args[0], args[1])
+
+			return bm.AddBoilerplate(&boilerplate.Boilerplate{
+				Name:     args[0],
+				Value:    value,
+				Raw:      raw,
+				Trusted:  trusted,
+				Scaffold: scaffold,
+			})
 		},
 	}
 	boilerplateEditCmd = &cobra.Command{
@@ -129,7 +191,11 @@ to the EDITOR environment variable. If neither is set, a system default editor
 will be used.
 
 If both name and content are provided, the boilerplate will be edited
-immediately with the specified content.`,
+immediately with the specified content.
+
+Use --raw or --raw=false to change whether the boilerplate is expanded,
+--trusted or --trusted=false to change whether its shell commands are run, and
+--scaffold or --scaffold=false to change whether it describes a tree of files.`,
 		Example: `  # Open editor to edit a boilerplate interactively
   ezbp boilerplate edit my-boilerplate-name
 
@@ -160,15 +226,29 @@ immediately with the specified content.`,
 				return fmt.Errorf("unknown boilerplate %q", args[0])
 			}
 
+			value := bp.Value
 			if len(args) == 1 {
-				content := bp.Value
-				value, err := editor.Edit(config.Editor, content)
+				var err error
+				value, err = editor.Edit(config.Editor, value)
 				if err != nil {
 					return err
 				}
-				return bm.Edit(args[0], value)
+			} else {
+				value = args[1]
+			}
+
+			edited := *bp
+			edited.Value = value
+			if cmd.Flags().Changed("raw") {
+				edited.Raw = raw
+			}
+			if cmd.Flags().Changed("trusted") {
+				edited.Trusted = trusted
+			}
+			if cmd.Flags().Changed("scaffold") {
+				edited.Scaffold = scaffold
 			}
-			return bm.Edit(args[0], args[1])
+			return bm.EditBoilerplate(&edited)
 		},
 	}
 	boilerplateDelCmd = &cobra.Command{
@@ -202,8 +282,29 @@ Use with caution as this operation cannot be undone.`,
 		},
 	}
 	boilerplateExpandCmd = &cobra.Command{
-		Use:      "expand",
-		Short:    "Expand a boilerplate.",
+		Use:   "expand [name]",
+		Short: "Expand a boilerplate.",
+		Long: `Expand a boilerplate and copy the result to the clipboard, or write it to
+the output given by --output: "stdout", "file:<path>" (overwritten) or
+"append:<path>".
+
+Without name, the boilerplate is selected in the UI.
+
+Variables can be answered beforehand with --set, or with --answers from a JSON
+or TOML file mapping variable names to values, --set taking precedence. Lists
+answer multi-select variables, as does repeating --set for the same variable.
+With --non-interactive, nothing is asked: variables without answer get their
+default value, and the expansion fails with the list of those without default.
+
+With --dry-run, the result is shown before being written: it can be accepted,
+edited, answered again or discarded. The usage count of the boilerplate is only
+incremented once the result is accepted. With --non-interactive, the result is
+printed and discarded.`,
+		Example: `  # Answer some variables, the others are asked
+  ezbp boilerplate expand incident --set title="DB down" --set services=api --set services=db
+
+  # Expand from a script, failing if a variable has no answer
+  ezbp boilerplate expand incident --answers answers.json --non-interactive --output stdout`,
 		Args:     cobra.RangeArgs(0, 1),
 		PreRunE:  setupRuntime,
 		PostRunE: tearDownRuntime,
@@ -211,6 +312,153 @@ Use with caution as this operation cannot be undone.`,
 			return boilerplateExpand(args) // Pass the flag value
 		},
 	}
+	expandCmd = &cobra.Command{
+		Use:   "expand [name]",
+		Short: "Expand a boilerplate, or an ad-hoc template.",
+		Long: `Expand a boilerplate, as "ezbp boilerplate expand" does, or an ad-hoc template.
+
+With --template or --template-file, the given text is expanded like a boilerplate
+without being saved. "--template -" reads it from stdin. Its [[includes]] are
+resolved against the saved boilerplates, and no usage count is incremented.
+
+See "ezbp boilerplate expand --help" for the other flags.`,
+		Example: `  # Expand a template from stdin
+  echo 'Hi {{Name}}, [[signature]]' | ezbp expand --template - --output stdout
+
+  # Expand a template file from a script
+  ezbp expand --template-file release.md --set version=1.2.0 --non-interactive -o stdout`,
+		Args:     cobra.RangeArgs(0, 1),
+		PreRunE:  setupRuntime,
+		PostRunE: tearDownRuntime,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("template") && !cmd.Flags().Changed("template-file") {
+				return boilerplateExpand(args)
+			}
+			if len(args) > 0 {
+				return fmt.Errorf("a boilerplate name can't be given with a template")
+			}
+			return templateExpand()
+		},
+	}
+	renderCmd = &cobra.Command{
+		Use:   "render <file>...",
+		Short: "Expand boilerplates inside documents.",
+		Long: `Expand the boilerplate references and placeholders inside documents, and print
+the result to stdout, or rewrite the documents with --in-place.
+
+Documents can delimit regions with marker comments, in HTML comments or in line
+comments starting with "#" or "//":
+
+  <!-- ezbp:begin [[oncall service="api"]] -->
+  <!-- ezbp:end -->
+
+Only the content of the regions is then replaced by the expansion of the
+template of their begin marker, the rest of the document being left as-is.
+The markers are kept, so that documents can be rendered again when boilerplates
+change. Documents without regions are expanded as a whole, but can't be
+rendered in place. "-" reads a document from stdin.
+
+The variables of a document are asked once, and can be answered with --set,
+--answers and --non-interactive as for "ezbp boilerplate expand". No usage
+count is incremented.`,
+		Example: `  # Refresh the shared blocks of runbooks
+  ezbp render --in-place docs/runbooks/*.md
+
+  # Expand a whole document
+  ezbp render CHANGELOG.tmpl.md --set version=1.2.0 > CHANGELOG.md`,
+		Args:     cobra.MinimumNArgs(1),
+		PreRunE:  setupRuntime,
+		PostRunE: tearDownRuntime,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return render(args)
+		},
+	}
+	scaffoldCmd = &cobra.Command{
+		Use:   "scaffold <name> <dest>",
+		Short: "Write the tree of files of a scaffold.",
+		Long: `Expand a scaffold, a boilerplate added with --scaffold, and write its files
+under the directory dest, which is created if needed.
+
+A scaffold lists files, each one starting with a header line giving its path,
+followed by its content:
+
+  ==> {{Name}}/go.mod <==
+  module example.com/{{Name}}
+  ==> {{Name}}/cmd/{{Name}}/main.go <==
+  package main
+  ==> {{Name}}/internal/ <==
+
+Paths ending with a slash are empty directories. Paths and contents are
+expanded together: each variable is asked once and shared by all the files.
+Files whose path expands to nothing, as with {{#if}} sections, are skipped.
+Paths must stay inside dest.
+
+Existing files are not overwritten, and nothing is written if any exists,
+unless --force is given. The files written are reported.
+
+Variables can be answered with --set, --answers and --non-interactive as for
+"ezbp boilerplate expand".`,
+		Example: `  # Create a Go module in the current directory
+  ezbp scaffold go-module . --set Name=demo
+
+  # Regenerate the files of a service, overwriting them
+  ezbp scaffold service services/ --answers billing.toml --force`,
+		Args:     cobra.ExactArgs(2),
+		PreRunE:  setupRuntime,
+		PostRunE: tearDownRuntime,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return bm.Names(), cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return scaffoldFiles(args[0], args[1])
+		},
+	}
+	boilerplateMergeCmd = &cobra.Command{
+		Use:   "merge <name> --data <file>",
+		Short: "Expand a boilerplate for each row of a dataset.",
+		Long: `Expand a boilerplate once for each row of a dataset, as in a mail merge, and
+print the expansions to stdout.
+
+The dataset given with --data is a CSV file, whose header row gives the variable
+answered by each column, or a JSON file holding an array of objects mapping
+variable names to values. Repeating a CSV column, or giving a JSON list, answers
+a multi-select variable. The variables the dataset doesn't answer are asked once,
+and their answers are shared by all the rows. --set and --answers answer
+variables for all the rows, the dataset taking precedence over them.
+
+With --files, each expansion is written to the file named by the expansion of
+the given template, which can refer to the variables of the boilerplate. File
+paths must be relative and inside the current directory, and existing files are
+only overwritten with --force. The files written are reported.
+
+With --jsonl, the expansions are printed as a stream of JSON objects, one per
+line, holding the row number, its answers and the expansion:
+
+  {"row":1,"answers":{"email":"alice@example.com"},"value":"Hi Alice, ..."}`,
+		Example: `  # Print an invitation for each attendee
+  ezbp boilerplate merge invite --data attendees.csv
+
+  # Write a letter per customer
+  ezbp boilerplate merge renewal --data customers.json --files 'letters/{{customer | snake}}.md'
+
+  # Send the expansions to a script
+  ezbp boilerplate merge invite --data attendees.csv --jsonl --non-interactive | ./send.py`,
+		Args:     cobra.ExactArgs(1),
+		PreRunE:  setupRuntime,
+		PostRunE: tearDownRuntime,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return bm.Names(), cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return merge(args[0])
+		},
+	}
 	boilerplateImportCmd = &cobra.Command{
 		Use:   "import <file.csv>",
 		Short: "Import boilerplates from a CSV file",
@@ -230,8 +478,33 @@ choose whether to keep the existing value, update it, or apply the choice for al
 			return bm.ImportBoilerplatesFromCSV(args[0])
 		},
 	}
+	// clearClipboardCmd is started in the background after copying a boilerplate
+	// expanded with secret answers. It clears the clipboard after a delay, unless
+	// its content has changed in the meantime.
+	clearClipboardCmd = &cobra.Command{
+		Use:    "clear-clipboard",
+		Short:  "Clear the clipboard after a delay.",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			time.Sleep(clearAfter)
+
+			current, err := clipboard.ReadAll()
+			if err != nil {
+				return err
+			}
+			if digest(current) != os.Getenv(clipboardDigestEnv) {
+				return nil
+			}
+			return clipboard.WriteAll("")
+		},
+	}
 )
 
+// clipboardDigestEnv passes the digest of the clipboard content to clear to clearClipboardCmd,
+// so that the content itself never appears in the process arguments or environment.
+const clipboardDigestEnv = "EZBP_CLIPBOARD_DIGEST"
+
 // TODO: Define more commands and flags based on these comments.
 // - boilerplate
 //   - list: List boilerplates
@@ -244,18 +517,49 @@ func main() {
 	// Flags
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Overrides default configuration path.")
 
-	boilerplateExpandCmd.Flags().BoolVarP(&forever, "forever", "f", false, "Continuously expand boilerplates.")
-	boilerplateExpandCmd.Flags().StringVar(&ui, "ui", "", "Specify UI: 'terminal' or 'rofi'. Overrides config.")
+	boilerplateAddCmd.Flags().BoolVar(&raw, "raw", false, "Never expand the placeholders of this boilerplate.")
+	boilerplateEditCmd.Flags().BoolVar(&raw, "raw", false, "Set whether this boilerplate is raw, i.e. never expanded.")
+	boilerplateAddCmd.Flags().BoolVar(&trusted, "trusted", false, "Run the shell commands of this boilerplate, even if they are not allowed by the config.")
+	boilerplateEditCmd.Flags().BoolVar(&trusted, "trusted", false, "Set whether the shell commands of this boilerplate are run, even if they are not allowed by the config.")
+	boilerplateAddCmd.Flags().BoolVar(&scaffold, "scaffold", false, "Make this boilerplate a tree of files, written with 'ezbp scaffold'.")
+	boilerplateEditCmd.Flags().BoolVar(&scaffold, "scaffold", false, "Set whether this boilerplate is a tree of files, written with 'ezbp scaffold'.")
+
+	clearClipboardCmd.Flags().DurationVar(&clearAfter, "after", 0, "Delay before clearing the clipboard.")
+
+	for _, cmd := range []*cobra.Command{boilerplateExpandCmd, expandCmd, renderCmd, scaffoldCmd, boilerplateMergeCmd} {
+		cmd.Flags().StringVar(&ui, "ui", "", "Specify UI: 'terminal', 'rofi' or 'none'. Overrides config.")
+		cmd.Flags().StringArrayVar(&sets, "set", nil, "Answer a variable, as in --set name=value. Can be repeated.")
+		cmd.Flags().StringVar(&answersPath, "answers", "", "Answer variables from a JSON or TOML file.")
+		cmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Never ask anything, and fail if a variable has no answer nor default value.")
+	}
+	for _, cmd := range []*cobra.Command{boilerplateExpandCmd, expandCmd} {
+		cmd.Flags().BoolVarP(&forever, "forever", "f", false, "Continuously expand boilerplates.")
+		cmd.Flags().StringVarP(&output, "output", "o", "", "Write to 'clipboard', 'stdout', 'file:<path>' or 'append:<path>'. Overrides config.")
+		cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the result, and only write it once accepted.")
+	}
+	expandCmd.Flags().StringVar(&template, "template", "", "Expand this template instead of a boilerplate, '-' to read it from stdin.")
+	expandCmd.Flags().StringVar(&templatePath, "template-file", "", "Expand the template of this file instead of a boilerplate.")
+	expandCmd.MarkFlagsMutuallyExclusive("template", "template-file")
+	renderCmd.Flags().BoolVarP(&inPlace, "in-place", "i", false, "Rewrite the regions of the documents instead of printing them.")
+	for _, cmd := range []*cobra.Command{scaffoldCmd, boilerplateMergeCmd} {
+		cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing files.")
+	}
+	boilerplateMergeCmd.Flags().StringVar(&dataPath, "data", "", "Read the rows from this CSV or JSON file.")
+	boilerplateMergeCmd.Flags().StringVar(&filesTemplate, "files", "", "Write each expansion to the file named by this template.")
+	boilerplateMergeCmd.Flags().BoolVar(&jsonl, "jsonl", false, "Print the expansions as JSON lines.")
+	boilerplateMergeCmd.MarkFlagRequired("data")
+	boilerplateMergeCmd.MarkFlagsMutuallyExclusive("files", "jsonl")
 
 	boilerplateCmd.AddCommand(
 		boilerplateAddCmd,
 		boilerplateEditCmd,
 		boilerplateDelCmd,
 		boilerplateExpandCmd,
+		boilerplateMergeCmd,
 		boilerplateImportCmd,
 	)
 
-	rootCmd.AddCommand(boilerplateCmd)
+	rootCmd.AddCommand(boilerplateCmd, expandCmd, renderCmd, scaffoldCmd, clearClipboardCmd)
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -263,22 +567,30 @@ func main() {
 
 // boilerplateExpand handles the logic for the "boilerplate expand" command.
 // It creates a new BoilerplateManager, prompts the user to select a boilerplate,
-// expands the selected boilerplate, and copies the result to the clipboard.
+// expands the selected boilerplate, and writes the result to the output (the clipboard by default).
 // This function is designed to run in a loop, allowing the user to expand multiple boilerplates.
 func boilerplateExpand(args []string) error {
+	opts, err := expandOptions()
+	if err != nil {
+		return err
+	}
+
 	if len(args) == 1 {
 		// Expand the selected boilerplate.
-		value, err := bm.Expand(args[0])
+		value, ok, err := expand(boilerplateSource(args[0]), opts)
 		if err != nil {
 			return fmt.Errorf("failed to expand boilerplate %q: %w", args[0], err)
 		}
-
-		// Copy the expanded boilerplate to the clipboard.
-		if err := clipboard.WriteAll(value); err != nil {
-			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		if !ok {
+			return nil
 		}
 
-		return nil
+		// Write the expanded boilerplate to the output.
+		return writeOutput(boilerplateSource(args[0]), value)
+	}
+
+	if nonInteractive {
+		return fmt.Errorf("a boilerplate name is required with --non-interactive")
 	}
 
 	// Loop indefinitely to allow expanding multiple boilerplates.
@@ -290,14 +602,16 @@ func boilerplateExpand(args []string) error {
 		}
 
 		// Expand the selected boilerplate.
-		value, err := bm.Expand(name)
+		value, ok, err := expand(boilerplateSource(name), opts)
 		if err != nil {
 			return fmt.Errorf("failed to expand boilerplate %q: %w", name, err)
 		}
 
-		// Copy the expanded boilerplate to the clipboard.
-		if err := clipboard.WriteAll(value); err != nil {
-			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		// Write the expanded boilerplate to the output, unless it was discarded.
+		if ok {
+			if err := writeOutput(boilerplateSource(name), value); err != nil {
+				return err
+			}
 		}
 
 		if !forever {
@@ -307,3 +621,325 @@ func boilerplateExpand(args []string) error {
 
 	return nil
 }
+
+// templateExpand handles the logic for expanding the template given with --template or
+// --template-file, and writes the result to the output.
+func templateExpand() error {
+	var (
+		content []byte
+		err     error
+	)
+	switch {
+	case templatePath != "":
+		content, err = os.ReadFile(templatePath)
+	case template == "-":
+		content, err = io.ReadAll(os.Stdin)
+		reopenTerminal()
+	default:
+		content = []byte(template)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+
+	opts, err := expandOptions()
+	if err != nil {
+		return err
+	}
+
+	src := templateSource(string(content))
+	value, ok, err := expand(src, opts)
+	if err != nil {
+		return fmt.Errorf("failed to expand template: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	return writeOutput(src, value)
+}
+
+// render handles the logic for the "render" command: it renders documents, printing
+// them to stdout or rewriting them in place.
+func render(paths []string) error {
+	opts, err := expandOptions()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		var content []byte
+		if path == "-" {
+			if inPlace {
+				return fmt.Errorf("stdin can't be rendered in place")
+			}
+			content, err = io.ReadAll(os.Stdin)
+			reopenTerminal()
+		} else {
+			content, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read document: %w", err)
+		}
+
+		doc := string(content)
+		if inPlace && !engine.HasRegions(doc) {
+			return fmt.Errorf("no ezbp:begin marker to render in place in %s", path)
+		}
+
+		value, err := bm.RenderDocument(doc, opts)
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", path, err)
+		}
+
+		if !inPlace {
+			fmt.Print(value)
+			continue
+		}
+		if value == doc {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(value), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// scaffoldFiles handles the logic for the "scaffold" command: it expands a scaffold,
+// writes its files under dest and reports them.
+func scaffoldFiles(name, dest string) error {
+	opts, err := expandOptions()
+	if err != nil {
+		return err
+	}
+
+	// The usage is only recorded once the files are written.
+	opts.DryRun = true
+	files, err := bm.Scaffold(name, opts)
+	if err != nil {
+		return fmt.Errorf("failed to expand scaffold %q: %w", name, err)
+	}
+
+	if err := writeFiles(dest, files); err != nil {
+		return err
+	}
+
+	bm.RecordUsage(name)
+	return nil
+}
+
+// merge handles the logic for the "boilerplate merge" command: it expands a boilerplate
+// for each row of the dataset, and prints the expansions or writes them to files.
+func merge(name string) error {
+	rows, err := engine.LoadDataset(dataPath)
+	if err != nil {
+		return err
+	}
+	opts, err := expandOptions()
+	if err != nil {
+		return err
+	}
+
+	// The usage is only recorded once the expansions are written.
+	opts.DryRun = true
+	merged, err := bm.Merge(name, rows, filesTemplate, opts)
+	if err != nil {
+		return fmt.Errorf("failed to merge boilerplate %q: %w", name, err)
+	}
+
+	switch {
+	case filesTemplate != "":
+		files := make([]engine.File, 0, len(merged))
+		for _, m := range merged {
+			files = append(files, engine.File{Path: m.Path, Content: m.Value})
+		}
+		if err := writeFiles(".", files); err != nil {
+			return err
+		}
+
+	case jsonl:
+		enc := json.NewEncoder(os.Stdout)
+		for i, m := range merged {
+			record := struct {
+				Row     int            `json:"row"`
+				Answers engine.Answers `json:"answers"`
+				Value   string         `json:"value"`
+			}{i + 1, m.Row, m.Value}
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+
+	default:
+		for _, m := range merged {
+			fmt.Print(m.Value)
+			if !strings.HasSuffix(m.Value, "\n") {
+				fmt.Println()
+			}
+		}
+	}
+
+	bm.RecordUsage(name)
+	return nil
+}
+
+// writeFiles writes files under dest, and reports the files written.
+func writeFiles(dest string, files []engine.File) error {
+	written, err := engine.WriteFiles(dest, files, force)
+	for _, file := range written {
+		action := "created"
+		if file.Overwritten {
+			action = "overwritten"
+		}
+		fmt.Printf("%s %s\n", action, file.Path)
+	}
+	if errors.Is(err, engine.ErrFileExists) {
+		return fmt.Errorf("%w, use --force to overwrite them", err)
+	}
+	return err
+}
+
+// reopenTerminal makes the terminal the standard input again once a template was read from it,
+// so that the UI can still read the user's answers. It does nothing if there is no terminal.
+func reopenTerminal() {
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		os.Stdin = tty
+	}
+}
+
+// source is what is expanded: a saved boilerplate, or a template.
+type source struct {
+	// expand, review and hasSecrets call the engine methods of the same name
+	// for boilerplates, or their template counterparts.
+	expand     func(opts engine.ExpandOptions) (string, error)
+	review     func(opts engine.ExpandOptions, accept string) (string, bool, error)
+	hasSecrets func() (bool, error)
+}
+
+func boilerplateSource(name string) source {
+	return source{
+		expand: func(opts engine.ExpandOptions) (string, error) {
+			return bm.ExpandWith(name, opts)
+		},
+		review: func(opts engine.ExpandOptions, accept string) (string, bool, error) {
+			return bm.ExpandReviewed(name, opts, accept)
+		},
+		hasSecrets: func() (bool, error) {
+			return bm.HasSecrets(name)
+		},
+	}
+}
+
+func templateSource(template string) source {
+	return source{
+		expand: func(opts engine.ExpandOptions) (string, error) {
+			return bm.ExpandTemplate(template, opts)
+		},
+		review: func(opts engine.ExpandOptions, accept string) (string, bool, error) {
+			return bm.ExpandTemplateReviewed(template, opts, accept)
+		},
+		hasSecrets: func() (bool, error) {
+			return bm.TemplateHasSecrets(template)
+		},
+	}
+}
+
+// expand expands a source, letting the user review the result with --dry-run.
+// It returns false if the result was discarded.
+func expand(src source, opts engine.ExpandOptions) (string, bool, error) {
+	if !dryRun {
+		value, err := src.expand(opts)
+		return value, err == nil, err
+	}
+
+	if nonInteractive {
+		opts.DryRun = true
+		value, err := src.expand(opts)
+		if err != nil {
+			return "", false, err
+		}
+		fmt.Println(value)
+		return "", false, nil
+	}
+
+	out, err := engine.ParseOutput(config.Output)
+	if err != nil {
+		return "", false, err
+	}
+	return src.review(opts, out.Action())
+}
+
+// expandOptions builds the expansion options from the --set, --answers and --non-interactive flags.
+func expandOptions() (engine.ExpandOptions, error) {
+	opts := engine.ExpandOptions{
+		Answers:        engine.Answers{},
+		NonInteractive: nonInteractive,
+	}
+
+	if answersPath != "" {
+		answers, err := engine.LoadAnswers(answersPath)
+		if err != nil {
+			return engine.ExpandOptions{}, err
+		}
+		opts.Answers = answers
+	}
+
+	// Variables set on the command line replace those of the answers file.
+	set := engine.Answers{}
+	for _, kv := range sets {
+		name, value, found := strings.Cut(kv, "=")
+		if !found || strings.TrimSpace(name) == "" {
+			return engine.ExpandOptions{}, fmt.Errorf("invalid --set %q: expected name=value", kv)
+		}
+		set.Add(strings.TrimSpace(name), value)
+	}
+	for name, value := range set {
+		opts.Answers[name] = value
+	}
+
+	return opts, nil
+}
+
+// writeOutput writes an expanded source to the configured output.
+// If it is copied to the clipboard, the source asks secret prompts and
+// clipboard_clear_after is set, the clipboard is cleared after that delay by
+// a background process.
+func writeOutput(src source, value string) error {
+	out, err := engine.ParseOutput(config.Output)
+	if err != nil {
+		return err
+	}
+	if err := out.Write(value); err != nil {
+		return err
+	}
+
+	if out.Kind != engine.OutputClipboard || config.ClipboardClearAfter <= 0 {
+		return nil
+	}
+	secret, err := src.hasSecrets()
+	if err != nil || !secret {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to schedule clipboard clearing: %w", err)
+	}
+	cmd := exec.Command(exe, "clear-clipboard", "--after", config.ClipboardClearAfter.String())
+	cmd.Env = append(os.Environ(), clipboardDigestEnv+"="+digest(value))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to schedule clipboard clearing: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// digest returns the hex-encoded SHA-256 of s.
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}